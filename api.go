@@ -6,22 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
-var validToolIDs = []string{
-	"codex_cli", "claude_code_cli", "cursor", "vscode", "jetbrains", "openai_cli_custom",
-}
-
-func isValidToolID(id string) bool {
-	for _, t := range validToolIDs {
-		if t == id {
-			return true
-		}
-	}
-	return false
-}
-
 type workspaceResp struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -43,6 +31,25 @@ type createCliKeyReq struct {
 	Scope      string `json:"scope,omitempty"` // user (default), workspace
 }
 
+// usageRow is one key's consumption on one day.
+type usageRow struct {
+	Date         string  `json:"date"` // YYYY-MM-DD, UTC
+	KeyID        string  `json:"key_id"`
+	KeyPrefix    string  `json:"key_prefix"`
+	ToolID       string  `json:"tool_id"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+type usageResp struct {
+	WorkspaceID string     `json:"workspace_id"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Rows        []usageRow `json:"rows"`
+}
+
 type createCliKeyResp struct {
 	ID        string `json:"id"`
 	Key       string `json:"key"`
@@ -57,6 +64,27 @@ type apiError struct {
 	Code  string `json:"code"`
 }
 
+// apiStatusError is a non-success HTTP response from the a21e API.
+type apiStatusError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("API %d: %s", e.Status, e.Message)
+}
+
+func newAPIStatusError(status int, raw []byte) error {
+	var ae apiError
+	_ = json.Unmarshal(raw, &ae)
+	msg := ae.Error
+	if msg == "" {
+		msg = strings.TrimSpace(string(raw))
+	}
+	return &apiStatusError{Status: status, Code: ae.Code, Message: msg}
+}
+
 type apiKeyListItem struct {
 	ID        string `json:"id"`
 	KeyPrefix string `json:"key_prefix"`
@@ -64,7 +92,7 @@ type apiKeyListItem struct {
 }
 
 func apiRequest(apiKey, baseURL, method, path string, body interface{}) ([]byte, int, error) {
-	url := strings.TrimSuffix(baseURL, "/") + path
+	url := endpointsFor(baseURL).API + path
 	var bodyReader io.Reader
 	if body != nil {
 		b, err := json.Marshal(body)
@@ -78,8 +106,10 @@ func apiRequest(apiKey, baseURL, method, path string, body interface{}) ([]byte,
 		return nil, 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", apiKey)
-	resp, err := http.DefaultClient.Do(req)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := httpClient().Do(req)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -97,13 +127,7 @@ func getDefaultWorkspace(apiKey, baseURL string) (*defaultWorkspaceResp, error)
 		return nil, err
 	}
 	if code != http.StatusOK {
-		var ae apiError
-		_ = json.Unmarshal(raw, &ae)
-		msg := ae.Error
-		if msg == "" {
-			msg = string(raw)
-		}
-		return nil, fmt.Errorf("API %d: %s", code, msg)
+		return nil, newAPIStatusError(code, raw)
 	}
 	var w defaultWorkspaceResp
 	if err := json.Unmarshal(raw, &w); err != nil {
@@ -118,13 +142,7 @@ func listWorkspaces(apiKey, baseURL string) ([]workspaceResp, error) {
 		return nil, err
 	}
 	if code != http.StatusOK {
-		var ae apiError
-		_ = json.Unmarshal(raw, &ae)
-		msg := ae.Error
-		if msg == "" {
-			msg = string(raw)
-		}
-		return nil, fmt.Errorf("API %d: %s", code, msg)
+		return nil, newAPIStatusError(code, raw)
 	}
 	var r listWorkspacesResp
 	if err := json.Unmarshal(raw, &r); err != nil {
@@ -133,8 +151,26 @@ func listWorkspaces(apiKey, baseURL string) ([]workspaceResp, error) {
 	return r.Items, nil
 }
 
+// getWorkspaceUsage returns usage between from and to (inclusive, YYYY-MM-DD) grouped
+// by key, tool and day.
+func getWorkspaceUsage(apiKey, baseURL, workspaceID, from, to string) (*usageResp, error) {
+	q := url.Values{"from": {from}, "to": {to}, "group_by": {"key,tool_id,day"}}
+	raw, code, err := apiRequest(apiKey, baseURL, "GET", "/v1/workspaces/"+url.PathEscape(workspaceID)+"/usage?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, newAPIStatusError(code, raw)
+	}
+	var r usageResp
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 func createCLIKey(apiKey, baseURL, workspaceID, toolID, label, scope string) (*createCliKeyResp, error) {
-	req := createCliKeyReq{ToolID: toolID, Label: label}
+	req := createCliKeyReq{ToolID: apiToolID(toolID), Label: label}
 	if scope != "" {
 		req.Scope = scope
 	}
@@ -143,13 +179,7 @@ func createCLIKey(apiKey, baseURL, workspaceID, toolID, label, scope string) (*c
 		return nil, err
 	}
 	if code != http.StatusCreated && code != http.StatusOK {
-		var ae apiError
-		_ = json.Unmarshal(raw, &ae)
-		msg := ae.Error
-		if msg == "" {
-			msg = string(raw)
-		}
-		return nil, fmt.Errorf("API %d: %s", code, msg)
+		return nil, newAPIStatusError(code, raw)
 	}
 	var r createCliKeyResp
 	if err := json.Unmarshal(raw, &r); err != nil {
@@ -164,13 +194,7 @@ func listAPIKeysForUser(apiKey, baseURL string) ([]apiKeyListItem, error) {
 		return nil, err
 	}
 	if code != http.StatusOK {
-		var ae apiError
-		_ = json.Unmarshal(raw, &ae)
-		msg := ae.Error
-		if msg == "" {
-			msg = string(raw)
-		}
-		return nil, fmt.Errorf("API %d: %s", code, msg)
+		return nil, newAPIStatusError(code, raw)
 	}
 
 	var items []apiKeyListItem
@@ -195,13 +219,7 @@ func revokeApiKeyByID(apiKey, baseURL, keyID string) error {
 	if code == http.StatusOK || code == http.StatusNoContent {
 		return nil
 	}
-	var ae apiError
-	_ = json.Unmarshal(raw, &ae)
-	msg := ae.Error
-	if msg == "" {
-		msg = string(raw)
-	}
-	return fmt.Errorf("API %d: %s", code, msg)
+	return newAPIStatusError(code, raw)
 }
 
 func revokeBootstrapKeyIfPresent(apiKey, baseURL, bootstrapKey string) error {
@@ -0,0 +1,66 @@
+// cache.go — Small JSON caches under ~/.a21e/cache.
+//
+// Caches only save round trips; a missing, stale or unreadable entry is simply a
+// miss, and a failed write is ignored by callers.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type cacheEntry struct {
+	WrittenAt time.Time       `json:"written_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func cachePath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".a21e", "cache", name+".json"), nil
+}
+
+// readCache decodes entry name into v if it was written less than maxAge ago.
+func readCache(name string, maxAge time.Duration, v any) bool {
+	path, err := cachePath(name)
+	if err != nil {
+		return false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return false
+	}
+	if age := time.Since(e.WrittenAt); age < 0 || age > maxAge {
+		return false
+	}
+	return json.Unmarshal(e.Data, v) == nil
+}
+
+func writeCache(name string, v any) error {
+	path, err := cachePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(cacheEntry{WrittenAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create cache directory: %w", err)
+	}
+	return writeFileAtomic(path, raw, 0o600, nil)
+}
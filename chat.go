@@ -0,0 +1,177 @@
+// chat.go — `a21e chat` (interactive REPL) and `a21e complete` (one-shot from stdin).
+//
+// Both send chat completions to openAIBaseURL with the stored key and stream the
+// answer to stdout as it arrives, so a key can be smoke-tested and prompts scripted
+// without a separate tool.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// chatFlags are the options chat and complete share.
+type chatFlags struct {
+	model  *string
+	system *string
+	asJSON *bool
+}
+
+func newChatFlagSet(name string) (*flag.FlagSet, chatFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	return fs, chatFlags{
+		model:  fs.String("model", settingValue("model"), "Model to use"),
+		system: fs.String("system", "", "System prompt"),
+		asJSON: fs.Bool("json", false, "Print the result as JSON instead of streaming text (same as --output json)"),
+	}
+}
+
+func parseChatFlags(fs *flag.FlagSet, f chatFlags, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *f.asJSON {
+		outputMode = outputJSON
+	}
+}
+
+func (f chatFlags) initialMessages() []chatMessage {
+	if *f.system == "" {
+		return nil
+	}
+	return []chatMessage{{Role: "system", Content: *f.system}}
+}
+
+// streamTo returns the onDelta callback for streamChat: stdout in text mode, nothing
+// in JSON mode (the result document carries the content).
+func streamTo() func(string) {
+	if jsonOutput() {
+		return func(string) {}
+	}
+	return func(s string) { fmt.Fprint(os.Stdout, s) }
+}
+
+func runComplete(args []string) {
+	fs, f := newChatFlagSet("complete")
+	parseChatFlags(fs, f, args)
+
+	prompt := strings.Join(fs.Args(), " ")
+	if !isTerminal() {
+		in, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			exitWithError("complete", fmt.Errorf("could not read stdin: %w", err))
+		}
+		if s := strings.TrimSpace(string(in)); s != "" {
+			prompt = strings.TrimSpace(prompt + "\n\n" + s)
+		}
+	}
+	if prompt == "" {
+		exitWithError("complete", withExitCode(exitValidation, errors.New("no prompt: pipe one on stdin or pass it as arguments")))
+	}
+
+	apiKey := requireAPIKey("complete")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	req := chatRequest{Model: *f.model, Messages: append(f.initialMessages(), chatMessage{Role: "user", Content: prompt})}
+	result, err := streamChat(ctx, openAIBaseURL(getAPIBaseURL()), apiKey, req, streamTo())
+	if err != nil {
+		exitWithError("complete", err)
+	}
+	if jsonOutput() {
+		writeJSON(result)
+		return
+	}
+	if !strings.HasSuffix(result.Content, "\n") {
+		fmt.Println()
+	}
+}
+
+// chatTranscript is what `chat --json` prints when the session ends.
+type chatTranscript struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+func runChat(args []string) {
+	fs, f := newChatFlagSet("chat")
+	parseChatFlags(fs, f, args)
+	if fs.NArg() > 0 {
+		exitWithError("chat", withExitCode(exitValidation, fmt.Errorf("unexpected argument %q (use a21e complete for one-shot prompts)", fs.Arg(0))))
+	}
+
+	apiKey := requireAPIKey("chat")
+	base := openAIBaseURL(getAPIBaseURL())
+	interactive := isTerminal()
+	if interactive {
+		fmt.Fprintf(os.Stderr, "Chatting with %s. /reset clears the conversation, /history shows it, /exit quits.\n", *f.model)
+	}
+
+	messages := f.initialMessages()
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 1024*1024)
+loop:
+	for {
+		if interactive {
+			fmt.Fprint(os.Stderr, "you> ")
+		}
+		if !in.Scan() {
+			break
+		}
+		line := strings.TrimSpace(in.Text())
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			break loop
+		case "/reset":
+			messages = f.initialMessages()
+			fmt.Fprintln(os.Stderr, "Conversation cleared.")
+			continue
+		case "/history":
+			for _, m := range messages {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", m.Role, m.Content)
+			}
+			continue
+		}
+
+		messages = append(messages, chatMessage{Role: "user", Content: line})
+		// Ctrl-C while a reply streams cancels that reply, not the session.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		result, err := streamChat(ctx, base, apiKey, chatRequest{Model: *f.model, Messages: messages}, streamTo())
+		interrupted := ctx.Err() != nil
+		stop()
+		if !jsonOutput() {
+			fmt.Println()
+		}
+		if err != nil {
+			messages = messages[:len(messages)-1]
+			if interrupted {
+				fmt.Fprintln(os.Stderr, "(interrupted)")
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "a21e chat: %v\n", err)
+			if exitCodeFor(err) == exitAuth {
+				os.Exit(exitAuth)
+			}
+			continue
+		}
+		messages = append(messages, chatMessage{Role: "assistant", Content: result.Content})
+	}
+	if err := in.Err(); err != nil {
+		exitWithError("chat", fmt.Errorf("could not read input: %w", err))
+	}
+	if jsonOutput() {
+		writeJSON(chatTranscript{Model: *f.model, Messages: messages})
+	}
+}
@@ -0,0 +1,64 @@
+// clipboard.go — Copy text to the system clipboard through a local helper binary.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+type clipboardHelper struct {
+	name string
+	args []string
+}
+
+var errNoClipboardHelper = errors.New("no clipboard helper found (install wl-clipboard, xclip or xsel)")
+
+// clipboardHelpers lists the helpers to try, in order, for the current platform.
+func clipboardHelpers() []clipboardHelper {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardHelper{{name: "pbcopy"}}
+	case "windows":
+		return []clipboardHelper{{name: "clip"}}
+	}
+	var helpers []clipboardHelper
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		helpers = append(helpers, clipboardHelper{name: "wl-copy"})
+	}
+	return append(helpers,
+		clipboardHelper{name: "xclip", args: []string{"-selection", "clipboard"}},
+		clipboardHelper{name: "xsel", args: []string{"--clipboard", "--input"}},
+	)
+}
+
+// copyToClipboard pipes text into the first helper that succeeds and returns its name.
+// A helper that is installed but fails (wl-copy without a compositor, xclip without
+// an X display) falls through to the next one. The text is never passed on the
+// command line, so it does not show up in ps.
+func copyToClipboard(text string) (string, error) {
+	var firstErr error
+	for _, h := range clipboardHelpers() {
+		path, err := exec.LookPath(h.name)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, h.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s failed: %w", h.name, err)
+			}
+			continue
+		}
+		return h.name, nil
+	}
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return "", errNoClipboardHelper
+}
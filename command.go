@@ -0,0 +1,667 @@
+// command.go — The command tree: dispatch, generated --help, global flags and
+// "did you mean" suggestions.
+//
+// Each command is a node with a summary, an optional flag set constructor (the same
+// newXFlagSet the command's run function parses with) and a run function; groups
+// such as `config` only have subcommands. The top-level usage, every command's
+// --help and the shell completion scripts are generated from this tree, so a new
+// command or flag shows up everywhere once it is added here. Flags are checked
+// before a command runs, so a typo always exits 2 with the same kind of message.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is one node of the tree. The root is `a21e` itself.
+type command struct {
+	name         string
+	summary      string
+	args         string               // positional synopsis for the usage line, e.g. "<key> <value>"
+	argKind      string               // completion kind of the first positional argument, if any
+	flags        func() *flag.FlagSet // nil for commands without flags
+	run          func(args []string)  // nil for groups
+	subcommands  []*command
+	examples     []example
+	details      string // extra help text, printed after the flags
+	interspersed bool   // flags may also follow the positional arguments
+	hidden       bool   // left out of help and completion
+	parent       *command
+}
+
+type example struct {
+	command string
+	what    string
+}
+
+var commandTree *command
+
+// rootCommand returns the command tree, building it on first use.
+func rootCommand() *command {
+	if commandTree == nil {
+		commandTree = newCommandTree()
+	}
+	return commandTree
+}
+
+func newCommandTree() *command {
+	root := &command{
+		name:    "a21e",
+		summary: "Agent Performance Layer CLI",
+		args:    "<command>",
+		details: fmt.Sprintf(`Environment:
+  A21E_API_KEY   Optional override. If omitted, a21e uses ~/.a21e/credentials (browser-auth writes this file)
+  A21E_API_URL   API base URL (default %s)
+  A21E_TOOL_ID   Override auto-detected tool (e.g. cursor, vscode, jetbrains)
+  A21E_PROFILE   Profile from ~/.a21e/config.toml to use (see a21e config list)
+  A21E_CA_BUNDLE Extra PEM root certificates to trust; HTTPS_PROXY and NO_PROXY are honored
+
+Supported tool_id: %s
+
+Exit codes:
+  0 success, 1 unexpected error, 2 invalid input, 3 auth failure, 4 network failure, 5 apply failure,
+  6 configuration drift (a21e verify)
+`, defaultAPIURL, strings.Join(validToolIDs, ", ")),
+		subcommands: []*command{
+			{
+				name:    "init",
+				summary: "Create a key for a tool and configure it",
+				flags:   func() *flag.FlagSet { fs, _ := newInitFlagSet(); return fs },
+				run:     runInit,
+				examples: []example{
+					{"a21e init", "Browser auth if needed, then auto-detect the tool in Cursor/VS Code/JetBrains terminals, or prompt"},
+					{"a21e init --tool <tool_id>", "Browser auth if needed, then create a user-scoped tool key"},
+					{"a21e init --tool cursor,claude_code_cli,codex_cli", "One key per tool (or repeat --tool)"},
+					{"a21e init --all-detected --apply", "One key per tool installed on this machine"},
+					{"a21e init --tool <tool_id> --workspace <id>", "Create the key in a workspace (user-scoped by default)"},
+					{"a21e init --tool <tool_id> --workspace <id> --workspace-scoped", "Key bound to that workspace only"},
+					{"a21e init --tool <tool_id> --apply --model <model>", "Auto-apply settings and pin the tool's default model"},
+					{"a21e init --non-interactive --tool <id> --workspace <id> --yes", "CI mode"},
+					{"a21e init --non-interactive --tool <id> --export-format dotenv", "Also write the key for CI (--export-file <path>)"},
+					{"a21e init --device", "Sign in with a device code instead of the local browser redirect (e.g. over SSH)"},
+				},
+			},
+			{
+				name:    "keys",
+				summary: "Manage the saved API key",
+				subcommands: []*command{
+					{name: "reveal", summary: "Copy the saved API key (~/.a21e/credentials) to the clipboard", flags: newKeysRevealFlagSet, run: runKeysReveal},
+				},
+			},
+			{
+				name:    "detect",
+				summary: "Explain which tool init would detect, and why",
+				flags:   func() *flag.FlagSet { fs, _ := newDetectFlagSet(); return fs },
+				run:     runDetect,
+			},
+			{
+				name:    "apply",
+				summary: "Reconcile this machine with a team setup manifest",
+				flags:   func() *flag.FlagSet { fs, _ := newApplyFlagSet(); return fs },
+				run:     runApply,
+				examples: []example{
+					{"a21e apply -f a21e.yaml --dry-run", "Show what would change"},
+					{"a21e apply -f a21e.yaml", "Create missing keys and apply tool settings"},
+				},
+			},
+			{
+				name:    "verify",
+				summary: "Check applied tool settings still match and their keys are active",
+				flags:   func() *flag.FlagSet { fs, _ := newVerifyFlagSet(); return fs },
+				run:     runVerify,
+			},
+			{
+				name:    "proxy",
+				summary: "Serve an OpenAI-compatible /v1 on 127.0.0.1 that adds your key",
+				flags:   func() *flag.FlagSet { fs, _ := newProxyFlagSet(); return fs },
+				run:     runProxy,
+			},
+			{
+				name:    "chat",
+				summary: "Chat with a model from the terminal",
+				flags:   func() *flag.FlagSet { fs, _ := newChatFlagSet("chat"); return fs },
+				run:     runChat,
+			},
+			{
+				name:    "complete",
+				summary: "One-shot prompt from stdin or arguments, streamed to stdout",
+				args:    "[prompt...]",
+				flags:   func() *flag.FlagSet { fs, _ := newChatFlagSet("complete"); return fs },
+				run:     runComplete,
+				examples: []example{
+					{`a21e complete "Summarize this diff" < change.diff`, "Prompt from arguments, context from stdin"},
+				},
+			},
+			{
+				name:    "models",
+				summary: "List the models available to your key",
+				flags:   func() *flag.FlagSet { fs, _ := newModelsFlagSet(); return fs },
+				run:     runModels,
+			},
+			{
+				name:    "test-key",
+				summary: "Send a one-token request with the key each configured tool uses",
+				flags:   func() *flag.FlagSet { fs, _ := newTestKeyFlagSet(); return fs },
+				run:     runTestKey,
+			},
+			{
+				name:    "usage",
+				summary: "Usage per key, tool and day with sparklines",
+				flags:   func() *flag.FlagSet { fs, _ := newUsageFlagSet(); return fs },
+				run:     runUsage,
+				examples: []example{
+					{"a21e usage --days 90 --format csv --file usage.csv", "One row per key, tool and day"},
+				},
+			},
+			{
+				name:    "config",
+				summary: "Read and change ~/.a21e/config.toml",
+				details: fmt.Sprintf(`Settings: %s
+Precedence: flags > environment > profile (--profile or A21E_PROFILE) > config file > built-in
+`, strings.Join(configKeys(), ", ")),
+				subcommands: []*command{
+					{name: "list", summary: "Show every setting, its value and where it came from", run: runConfigList},
+					{name: "get", summary: "Print the effective value of a setting", args: "<key>", argKind: "config-keys", run: runConfigGet, interspersed: true},
+					{
+						name: "set", summary: "Change a setting", args: "<key> <value>", argKind: "config-keys",
+						flags: func() *flag.FlagSet { fs, _ := newConfigFlagSet("set"); return fs }, run: runConfigSet, interspersed: true,
+					},
+					{
+						name: "unset", summary: "Remove a setting", args: "<key>", argKind: "config-keys",
+						flags: func() *flag.FlagSet { fs, _ := newConfigFlagSet("unset"); return fs }, run: runConfigUnset, interspersed: true,
+					},
+				},
+			},
+			{
+				name:         "completion",
+				summary:      "Print or install shell completion",
+				args:         "bash|zsh|fish",
+				argKind:      "shells",
+				flags:        func() *flag.FlagSet { fs, _, _ := newCompletionFlagSet(); return fs },
+				run:          runCompletion,
+				interspersed: true,
+				examples: []example{
+					{"a21e completion zsh --install", "Load completion from ~/.zshrc"},
+					{"source <(a21e completion bash)", "Try it in the current shell"},
+				},
+			},
+			{
+				name:    "version",
+				summary: "Show version, build and API details and whether an update is available",
+				flags:   func() *flag.FlagSet { fs, _, _ := newVersionFlagSet(); return fs },
+				run:     runVersion,
+			},
+			{
+				name:    "self-update",
+				summary: "Install the latest signed release",
+				flags:   func() *flag.FlagSet { fs, _ := newSelfUpdateFlagSet(); return fs },
+				run:     runSelfUpdate,
+			},
+			{name: "help", summary: "Show help for a command", args: "[command...]", run: runHelp},
+			{name: "__complete", args: "<kind>", run: runCompleteValues, hidden: true},
+		},
+	}
+	root.link(nil)
+	return root
+}
+
+func (c *command) link(parent *command) {
+	c.parent = parent
+	for _, s := range c.subcommands {
+		s.link(c)
+	}
+}
+
+// path is the command's words after "a21e" ("" for the root).
+func (c *command) path() string {
+	if c.parent == nil {
+		return ""
+	}
+	if p := c.parent.path(); p != "" {
+		return p + " " + c.name
+	}
+	return c.name
+}
+
+// fullName is how the user types the command, e.g. "a21e config set".
+func (c *command) fullName() string {
+	if p := c.path(); p != "" {
+		return "a21e " + p
+	}
+	return "a21e"
+}
+
+func (c *command) visibleSubcommands() []*command {
+	var out []*command
+	for _, s := range c.subcommands {
+		if !s.hidden {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (c *command) child(name string) *command {
+	for _, s := range c.subcommands {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// find returns the command at path ("" is the root), or nil.
+func (c *command) find(path string) *command {
+	cmd := c
+	for _, name := range strings.Fields(path) {
+		if cmd = cmd.child(name); cmd == nil {
+			return nil
+		}
+	}
+	return cmd
+}
+
+// walk calls fn for c and every visible command below it, parents first.
+func (c *command) walk(fn func(*command)) {
+	fn(c)
+	for _, s := range c.visibleSubcommands() {
+		s.walk(fn)
+	}
+}
+
+// flagSet returns a fresh flag set for the command; commands without flags get an
+// empty one so -h and unknown flags are handled the same way everywhere.
+func (c *command) flagSet() *flag.FlagSet {
+	if c.flags != nil {
+		return c.flags()
+	}
+	return flag.NewFlagSet(c.path(), flag.ContinueOnError)
+}
+
+func (c *command) hasFlags() bool {
+	has := false
+	c.flagSet().VisitAll(func(*flag.Flag) { has = true })
+	return has
+}
+
+func (c *command) usageLine() string {
+	parts := []string{c.fullName()}
+	if c.run == nil && len(c.subcommands) > 0 {
+		parts = append(parts, "<command>")
+	} else if c.args != "" {
+		parts = append(parts, c.args)
+	}
+	if c.hasFlags() {
+		parts = append(parts, "[flags]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// --- global flags ---
+
+var verbose bool
+
+// newGlobalFlagSet defines the flags every command accepts, before or after its
+// name. A command's own flag of the same name (config set --profile) wins.
+func newGlobalFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("a21e", flag.ContinueOnError)
+	fs.Func("output", "Output format: json or text (json writes one result document to stdout)", func(v string) error {
+		if v != outputText && v != outputJSON {
+			return errors.New("want json or text")
+		}
+		outputMode = v
+		return nil
+	})
+	fs.Func("profile", "Config profile to use (overrides A21E_PROFILE)", func(v string) error {
+		profileOverride = v
+		return nil
+	})
+	fs.Func("api-url", "API base URL for this run (overrides A21E_API_URL and config)", func(v string) error {
+		if err := validateConfigURL(v); err != nil {
+			return err
+		}
+		flagSettings["api_url"] = flagSetting{flag: "--api-url", value: v}
+		return nil
+	})
+	fs.BoolFunc("verbose", "Log each HTTP request and its status to stderr", func(v string) error {
+		b, err := strconv.ParseBool(v)
+		verbose = b
+		return err
+	})
+	return fs
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	bf, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && bf.IsBoolFlag()
+}
+
+// splitFlag splits "--name=value" into its parts; ok is false for non-flags.
+func splitFlag(arg string) (name, value string, hasValue, ok bool) {
+	if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+		return "", "", false, false
+	}
+	name = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	name, value, hasValue = strings.Cut(name, "=")
+	if name == "o" {
+		name = "output"
+	}
+	return name, value, hasValue, true
+}
+
+// parseGlobalFlags strips global options from args, wherever they appear before a
+// "--", and applies them. Flags that local (the command's flag set, if any) also
+// defines are left for the command. The remaining args are returned in order.
+func parseGlobalFlags(args []string, local *flag.FlagSet) ([]string, error) {
+	globals := newGlobalFlagSet()
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue, ok := splitFlag(a)
+		g := globals.Lookup(name)
+		if !ok || g == nil || (local != nil && local.Lookup(name) != nil) {
+			rest = append(rest, a)
+			continue
+		}
+		switch {
+		case hasValue:
+		case isBoolFlag(g):
+			value = "true"
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			return nil, fmt.Errorf("--%s requires a value", name)
+		}
+		if err := g.Value.Set(value); err != nil {
+			return nil, fmt.Errorf("invalid --%s %q: %v", name, value, err)
+		}
+	}
+	return rest, nil
+}
+
+// --- dispatch ---
+
+// execute runs the command args name. It never returns on errors: those exit
+// with exitValidation, and commands exit with their own codes.
+func execute(args []string) {
+	root := rootCommand()
+	cmd, rest, err := root.resolve(args)
+	if err != nil {
+		exitWithError(cmd.path(), withExitCode(exitValidation, err))
+	}
+	if cmd == root && len(rest) > 0 && (rest[0] == "--version" || rest[0] == "-v") {
+		fmt.Println("a21e", version)
+		return
+	}
+	if cmd.run == nil && len(rest) == 0 {
+		writeHelp(os.Stderr, cmd)
+		if cmd == root {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if err := cmd.checkFlags(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			writeHelp(os.Stdout, cmd)
+			os.Exit(exitOK)
+		}
+		exitWithError(cmd.path(), withExitCode(exitValidation, err))
+	}
+	if cmd.run == nil {
+		exitWithError(cmd.path(), usageError(cmd.path()))
+	}
+	cmd.run(rest)
+}
+
+// resolve finds the command named by the leading words of args, skipping and
+// applying global flags on the way. It returns the command (the deepest one
+// reached, on error) and its arguments with global flags removed.
+func (c *command) resolve(args []string) (*command, []string, error) {
+	globals := newGlobalFlagSet()
+	cmd := c
+	var leading []string
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if name, _, hasValue, ok := splitFlag(a); ok {
+			g := globals.Lookup(name)
+			if g == nil {
+				break // the command's own flag
+			}
+			leading = append(leading, a)
+			if !hasValue && !isBoolFlag(g) && i+1 < len(args) {
+				i++
+				leading = append(leading, args[i])
+			}
+			continue
+		}
+		if len(cmd.subcommands) == 0 {
+			break
+		}
+		next := cmd.child(a)
+		if next == nil {
+			if cmd.run != nil {
+				break
+			}
+			return cmd, nil, unknownCommandError(cmd, a)
+		}
+		cmd = next
+	}
+	if _, err := parseGlobalFlags(leading, nil); err != nil {
+		return cmd, nil, err
+	}
+	rest, err := parseGlobalFlags(args[i:], cmd.flagSet())
+	if err != nil {
+		return cmd, nil, err
+	}
+	return cmd, rest, nil
+}
+
+// checkFlags parses args with the command's flag set, output discarded, so bad
+// flags are reported here rather than by each command.
+func (c *command) checkFlags(args []string) error {
+	fs := c.flagSet()
+	fs.SetOutput(io.Discard)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return c.explainFlagError(fs, err)
+		}
+		if !c.interspersed || fs.NArg() == 0 {
+			return nil
+		}
+		args = fs.Args()[1:]
+	}
+}
+
+func (c *command) explainFlagError(fs *flag.FlagSet, err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	name, ok := strings.CutPrefix(err.Error(), "flag provided but not defined: ")
+	if !ok {
+		msg := strings.Replace(err.Error(), "flag -", "flag --", 1)
+		return fmt.Errorf("%s (see %s --help)", msg, c.fullName())
+	}
+	name = strings.TrimLeft(name, "-")
+	var names []string
+	for _, set := range []*flag.FlagSet{fs, newGlobalFlagSet()} {
+		set.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	}
+	msg := "unknown flag --" + name
+	if s := suggest(name, names); s != "" {
+		msg += fmt.Sprintf("; did you mean --%s?", s)
+	}
+	return fmt.Errorf("%s (see %s --help)", msg, c.fullName())
+}
+
+func unknownCommandError(parent *command, name string) error {
+	var names []string
+	for _, s := range parent.visibleSubcommands() {
+		names = append(names, s.name)
+	}
+	msg := fmt.Sprintf("unknown command %q for %s", name, parent.fullName())
+	if s := suggest(name, names); s != "" {
+		msg += fmt.Sprintf("; did you mean %q?", s)
+	}
+	return fmt.Errorf("%s (see %s --help)", msg, parent.fullName())
+}
+
+// usageError is the validation error for wrong positional arguments to the
+// command at path.
+func usageError(path string) error {
+	c := rootCommand().find(path)
+	return withExitCode(exitValidation, fmt.Errorf("usage: %s (see %s --help)", c.usageLine(), c.fullName()))
+}
+
+// runHelp implements `a21e help [command...]`.
+func runHelp(args []string) {
+	root := rootCommand()
+	cmd := root
+	for _, name := range args {
+		next := cmd.child(name)
+		if next == nil || next.hidden {
+			exitWithError("help", withExitCode(exitValidation, unknownCommandError(cmd, name)))
+		}
+		cmd = next
+	}
+	writeHelp(os.Stdout, cmd)
+}
+
+// suggest returns the candidate closest to word, if any is close enough to be a
+// plausible typo: within two edits, or one that word is a prefix of.
+func suggest(word string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		d := editDistance(word, c)
+		if len(word) >= 3 && strings.HasPrefix(c, word) && d > 0 {
+			d = min(d, 2)
+		}
+		if d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// --- help ---
+
+// writeHelp prints the generated help for c.
+func writeHelp(w io.Writer, c *command) {
+	if c.parent == nil {
+		fmt.Fprintf(w, "%s — %s\n", c.name, c.summary)
+	} else {
+		fmt.Fprintf(w, "%s — %s\n", c.fullName(), c.summary)
+	}
+	fmt.Fprintf(w, "\nUsage:\n  %s\n", c.usageLine())
+
+	if subs := c.visibleSubcommands(); len(subs) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, s := range subs {
+			fmt.Fprintf(tw, "  %s\t%s\n", s.name, s.summary)
+		}
+		tw.Flush()
+	}
+	if len(c.examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, e := range c.examples {
+			fmt.Fprintf(tw, "  %s\t%s\n", e.command, e.what)
+		}
+		tw.Flush()
+	}
+	local := c.flagSet()
+	if c.parent != nil && c.hasFlags() {
+		fmt.Fprintln(w, "\nFlags:")
+		writeFlags(w, local, nil)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	writeFlags(w, newGlobalFlagSet(), local)
+	if c.details != "" {
+		fmt.Fprintf(w, "\n%s", c.details)
+	}
+	if len(c.visibleSubcommands()) > 0 {
+		fmt.Fprintf(w, "\nRun '%s <command> --help' for a command's flags and examples.\n", c.fullName())
+	}
+}
+
+// writeFlags lists the flags of fs, leaving out any that shadowedBy redefines.
+func writeFlags(w io.Writer, fs, shadowedBy *flag.FlagSet) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		if shadowedBy != nil && shadowedBy.Lookup(f.Name) != nil {
+			return
+		}
+		cf := describeFlag(f)
+		spec := cf.option()
+		if cf.hasValue {
+			spec += " " + flagPlaceholder(cf)
+		}
+		usage := f.Usage
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", spec, usage)
+	})
+	tw.Flush()
+}
+
+func flagPlaceholder(f completionFlag) string {
+	switch f.kind {
+	case "tools":
+		return "<tool_id>"
+	case "workspaces":
+		return "<id>"
+	case "files":
+		return "<path>"
+	case "profiles":
+		return "<name>"
+	case "outputs":
+		return "json|text"
+	case "channels":
+		return strings.Join(updateChannels, "|")
+	case "export-formats":
+		return "<format>"
+	case "usage-formats":
+		return "table|csv|json"
+	}
+	if strings.HasSuffix(f.name, "url") {
+		return "<url>"
+	}
+	return "<" + f.name + ">"
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func resetGlobalFlags() {
+	outputMode = outputText
+	profileOverride = ""
+	verbose = false
+	flagSettings = map[string]flagSetting{}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	defer resetGlobalFlags()
+
+	rest, err := parseGlobalFlags([]string{"init", "--output", "json", "--tool", "cursor"}, nil)
+	if err != nil {
+		t.Fatalf("parseGlobalFlags returned unexpected error: %v", err)
+	}
+	if outputMode != outputJSON {
+		t.Fatalf("outputMode = %q, want %q", outputMode, outputJSON)
+	}
+	if len(rest) != 3 || rest[0] != "init" || rest[1] != "--tool" || rest[2] != "cursor" {
+		t.Fatalf("unexpected remaining args: %v", rest)
+	}
+
+	if _, err := parseGlobalFlags([]string{"--output=yaml"}, nil); err == nil {
+		t.Fatalf("expected invalid output format to be rejected")
+	}
+	if _, err := parseGlobalFlags([]string{"--api-url", "not a url"}, nil); err == nil {
+		t.Fatalf("expected invalid --api-url to be rejected")
+	}
+
+	// A command's own flag of the same name is left for the command.
+	local, _ := newConfigFlagSet("set")
+	rest, err = parseGlobalFlags([]string{"--profile", "eu", "model", "m", "--verbose", "--api-url=http://127.0.0.1:9", "--", "--output"}, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--profile eu model m -- --output"; strings.Join(rest, " ") != want {
+		t.Fatalf("rest = %q, want %q", strings.Join(rest, " "), want)
+	}
+	if profileOverride != "" || !verbose || getAPIBaseURL() != "http://127.0.0.1:9" {
+		t.Fatalf("profile = %q, verbose = %v, api_url = %q", profileOverride, verbose, getAPIBaseURL())
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	defer resetGlobalFlags()
+	root := rootCommand()
+
+	testCases := []struct {
+		args     []string
+		wantPath string
+		wantRest string
+		wantErr  string
+	}{
+		{args: nil, wantPath: ""},
+		{args: []string{"--output", "json", "config", "set", "model", "m"}, wantPath: "config set", wantRest: "model m"},
+		{args: []string{"config", "--profile", "eu", "get", "model", "--verbose"}, wantPath: "config get", wantRest: "model"},
+		{args: []string{"init", "--tool", "cursor", "--output=json"}, wantPath: "init", wantRest: "--tool cursor"},
+		{args: []string{"chat", "--model", "m"}, wantPath: "chat", wantRest: "--model m"},
+		{args: []string{"--version"}, wantPath: "", wantRest: "--version"},
+		{args: []string{"inti"}, wantPath: "", wantErr: `unknown command "inti" for a21e; did you mean "init"?`},
+		{args: []string{"config", "lsit"}, wantPath: "config", wantErr: `did you mean "list"?`},
+		{args: []string{"xyzzy"}, wantPath: "", wantErr: `unknown command "xyzzy" for a21e (see a21e --help)`},
+	}
+	for _, tc := range testCases {
+		cmd, rest, err := root.resolve(tc.args)
+		if cmd.path() != tc.wantPath {
+			t.Errorf("resolve(%q) = %q, want %q", tc.args, cmd.path(), tc.wantPath)
+		}
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("resolve(%q) error = %v, want %q", tc.args, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(rest, " ") != tc.wantRest {
+			t.Errorf("resolve(%q) = %q, %v; want %q", tc.args, rest, err, tc.wantRest)
+		}
+	}
+	if activeProfile() != "eu" || outputMode != outputJSON || !verbose {
+		t.Fatalf("global flags not applied: profile %q, output %q, verbose %v", activeProfile(), outputMode, verbose)
+	}
+}
+
+func TestCheckFlags(t *testing.T) {
+	t.Parallel()
+	root := rootCommand()
+
+	testCases := []struct {
+		path    string
+		args    []string
+		wantErr string
+	}{
+		{path: "init", args: []string{"--tool", "cursor", "--apply"}},
+		{path: "init", args: []string{"--tol", "cursor"}, wantErr: "unknown flag --tol; did you mean --tool? (see a21e init --help)"},
+		{path: "usage", args: []string{"--days", "many"}, wantErr: "invalid value"},
+		{path: "config set", args: []string{"model", "m", "--profle", "eu"}, wantErr: "did you mean --profile?"},
+		{path: "completion", args: []string{"bash", "--install"}},
+		{path: "init", args: []string{"--verbos"}, wantErr: "did you mean --verbose?"},
+		{path: "keys reveal", args: []string{"-h"}, wantErr: flag.ErrHelp.Error()},
+	}
+	for _, tc := range testCases {
+		err := root.find(tc.path).checkFlags(tc.args)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s %q: unexpected error %v", tc.path, tc.args, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s %q: error = %v, want %q", tc.path, tc.args, err, tc.wantErr)
+		}
+	}
+	if err := root.find("keys reveal").checkFlags([]string{"--help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("--help = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	t.Parallel()
+	names := []string{"init", "keys", "detect", "config", "completion", "complete", "self-update"}
+	for word, want := range map[string]string{
+		"inti":       "init",
+		"key":        "keys",
+		"conf":       "config",
+		"completoin": "completion",
+		"selfupdate": "self-update",
+		"deploy":     "",
+		"x":          "",
+	} {
+		if got := suggest(word, names); got != want {
+			t.Errorf("suggest(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestHelpIsGeneratedFromFlagSets(t *testing.T) {
+	t.Parallel()
+	root := rootCommand()
+	root.walk(func(c *command) {
+		var b bytes.Buffer
+		writeHelp(&b, c)
+		help := b.String()
+		for _, want := range []string{c.usageLine(), "--output json|text", "--api-url <url>"} {
+			if !strings.Contains(help, want) {
+				t.Errorf("help for %q is missing %q:\n%s", c.path(), want, help)
+			}
+		}
+		if c.parent != nil {
+			for _, f := range c.completionFlags() {
+				if !strings.Contains(help, f.option()) || !strings.Contains(help, f.usage) {
+					t.Errorf("help for %q is missing %s", c.path(), f.option())
+				}
+			}
+		}
+		for _, s := range c.visibleSubcommands() {
+			if !strings.Contains(help, s.name) || !strings.Contains(help, s.summary) {
+				t.Errorf("help for %q is missing subcommand %q", c.path(), s.name)
+			}
+		}
+	})
+
+	var b bytes.Buffer
+	writeHelp(&b, root.find("init"))
+	for _, want := range []string{"a21e init [flags]", "--tool <tool_id>", "--workspace <id>", "--export-format <format>", "Examples:", "a21e init --all-detected --apply"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("init help is missing %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "__complete") {
+		t.Errorf("help lists a hidden command")
+	}
+}
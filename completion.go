@@ -0,0 +1,726 @@
+// completion.go — `a21e completion bash|zsh|fish`: shell completion scripts.
+//
+// The scripts are generated from the command tree (command.go) and each command's
+// flag.FlagSet, so new commands and flags complete without touching this file.
+// Flag values that depend on this machine or account (tool IDs, workspaces,
+// profiles, setting names) are looked up at completion time through the hidden
+// `a21e __complete <kind>` command, which prints value<TAB>label lines. Workspaces
+// are cached in ~/.a21e/cache for an hour, per API URL and key.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+const (
+	completionBlockStart = "# >>> a21e completion >>>"
+	completionBlockEnd   = "# <<< a21e completion <<<"
+)
+
+const (
+	workspaceCacheName = "workspaces"
+	workspaceCacheTTL  = time.Hour
+	// completionTimeout keeps a slow API from freezing the shell on <TAB>.
+	completionTimeout = 3 * time.Second
+)
+
+// flagValueKinds says how to complete the value of a flag, by flag name. Flags
+// that take a value and are not listed here get no suggestions.
+var flagValueKinds = map[string]string{
+	"tool":          "tools",
+	"workspace":     "workspaces",
+	"export-format": "export-formats",
+	"format":        "usage-formats",
+	"channel":       "channels",
+	"output":        "outputs",
+	"profile":       "profiles",
+	"f":             "files",
+	"file":          "files",
+	"export-file":   "files",
+}
+
+// completionFlag is one flag as the scripts see it.
+type completionFlag struct {
+	name     string // without dashes
+	usage    string
+	hasValue bool
+	kind     string
+}
+
+func (f completionFlag) option() string {
+	if len(f.name) == 1 {
+		return "-" + f.name
+	}
+	return "--" + f.name
+}
+
+func flagsOf(fs *flag.FlagSet) []completionFlag {
+	if fs == nil {
+		return nil
+	}
+	var out []completionFlag
+	fs.VisitAll(func(f *flag.Flag) { out = append(out, describeFlag(f)) })
+	return out
+}
+
+func describeFlag(f *flag.Flag) completionFlag {
+	cf := completionFlag{name: f.Name, usage: f.Usage, hasValue: !isBoolFlag(f)}
+	if cf.hasValue {
+		cf.kind = flagValueKinds[f.Name]
+	}
+	return cf
+}
+
+func (c *command) completionFlags() []completionFlag {
+	if c.flags == nil {
+		return nil
+	}
+	return flagsOf(c.flags())
+}
+
+// completionCommands lists every visible command below the root, parents first.
+func completionCommands() []*command {
+	var out []*command
+	rootCommand().walk(func(c *command) {
+		if c.parent != nil {
+			out = append(out, c)
+		}
+	})
+	return out
+}
+
+// commandPaths lists "" (the top level) and every command path.
+func commandPaths() []string {
+	var paths []string
+	rootCommand().walk(func(c *command) { paths = append(paths, c.path()) })
+	return paths
+}
+
+func subcommands(path string) []*command {
+	return rootCommand().find(path).visibleSubcommands()
+}
+
+func commandName(path string) string {
+	return path[strings.LastIndex(path, " ")+1:]
+}
+
+func newCompletionFlagSet() (*flag.FlagSet, *bool, *string) {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	install := fs.Bool("install", false, "Add the script to your shell's startup file instead of printing it")
+	file := fs.String("file", "", "File --install writes to (default: ~/.bashrc, ~/.zshrc or ~/.config/fish/completions/a21e.fish)")
+	return fs, install, file
+}
+
+// completionInstall is the completion --install --output json document.
+type completionInstall struct {
+	Shell   string `json:"shell"`
+	Path    string `json:"path"`
+	Changed bool   `json:"changed"`
+	Backup  string `json:"backup_path,omitempty"`
+}
+
+func runCompletion(args []string) {
+	fs, install, file := newCompletionFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	// Allow flags after the shell name too.
+	rest := fs.Args()
+	if len(rest) > 1 {
+		if err := fs.Parse(rest[1:]); err != nil {
+			os.Exit(exitValidation)
+		}
+		rest = append(rest[:1], fs.Args()...)
+	}
+	if len(rest) != 1 {
+		exitWithError("completion", usageError("completion"))
+	}
+	shell := rest[0]
+
+	if !*install {
+		if err := writeCompletionScript(os.Stdout, shell); err != nil {
+			exitWithError("completion", err)
+		}
+		return
+	}
+	res, err := installCompletion(shell, *file)
+	if err != nil {
+		exitWithError("completion", err)
+	}
+	if jsonOutput() {
+		writeJSON(res)
+		return
+	}
+	if !res.Changed {
+		fmt.Fprintf(os.Stderr, "Completion for %s is already installed in %s.\n", shell, res.Path)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Installed %s completion in %s. Open a new shell to use it.\n", shell, res.Path)
+}
+
+func writeCompletionScript(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		_, err := io.WriteString(w, bashCompletion())
+		return err
+	case "zsh":
+		_, err := io.WriteString(w, zshCompletion())
+		return err
+	case "fish":
+		_, err := io.WriteString(w, fishCompletion())
+		return err
+	default:
+		return withExitCode(exitValidation, fmt.Errorf("unsupported shell %q (want %s)", shell, strings.Join(completionShells, ", ")))
+	}
+}
+
+// installCompletion adds a managed block that loads the script to the shell's
+// startup file (for fish, its completions directory). Running it again is a no-op.
+func installCompletion(shell, path string) (*completionInstall, error) {
+	var line string
+	switch shell {
+	case "bash":
+		line = "command -v a21e >/dev/null 2>&1 && source <(a21e completion bash)"
+	case "zsh":
+		line = "if command -v a21e >/dev/null 2>&1; then\n  (( $+functions[compdef] )) || { autoload -Uz compinit && compinit; }\n  source <(a21e completion zsh)\nfi"
+	case "fish":
+		line = "a21e completion fish | source"
+	default:
+		return nil, withExitCode(exitValidation, fmt.Errorf("unsupported shell %q (want %s)", shell, strings.Join(completionShells, ", ")))
+	}
+	if path == "" {
+		var err error
+		if path, err = completionStartupFile(shell); err != nil {
+			return nil, err
+		}
+	}
+	path = expandHome(path)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	block := completionBlockStart + "\n" + line + "\n" + completionBlockEnd
+	updated, changed, err := upsertManagedBlock(string(existing), completionBlockStart, completionBlockEnd, block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	res := &completionInstall{Shell: shell, Path: path, Changed: changed}
+	if !changed {
+		return res, nil
+	}
+	backup, err := writeFileWithBackup(path, existing, []byte(updated), 0o644)
+	if err != nil {
+		return nil, err
+	}
+	res.Backup = backup
+	return res, nil
+}
+
+// completionStartupFile is where --install writes for shell. For the login shell
+// it is the same profile --apply uses (so shell_rc applies).
+func completionStartupFile(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+	if shell == "fish" {
+		return filepath.Join(home, ".config", "fish", "completions", "a21e.fish"), nil
+	}
+	if filepath.Base(os.Getenv("SHELL")) == shell {
+		return resolveShellRCPath()
+	}
+	return filepath.Join(home, "."+shell+"rc"), nil
+}
+
+// completionItem is one suggestion printed by `a21e __complete`.
+type completionItem struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+}
+
+// runCompleteValues implements the hidden `a21e __complete <kind>`. It never fails
+// loudly: a shell asking for completions wants suggestions or nothing.
+func runCompleteValues(args []string) {
+	if len(args) != 1 {
+		os.Exit(exitValidation)
+	}
+	for _, item := range completionValues(args[0]) {
+		if item.Label != "" {
+			fmt.Printf("%s\t%s\n", item.Value, item.Label)
+		} else {
+			fmt.Println(item.Value)
+		}
+	}
+}
+
+func completionValues(kind string) []completionItem {
+	var items []completionItem
+	add := func(values ...string) {
+		for _, v := range values {
+			items = append(items, completionItem{Value: v})
+		}
+	}
+	switch kind {
+	case "tools":
+		for _, id := range validToolIDs {
+			item := completionItem{Value: id}
+			if t, ok := lookupTool(id); ok {
+				item.Label = t.Label()
+			}
+			items = append(items, item)
+		}
+	case "workspaces":
+		items = cachedWorkspaces(getAPIBaseURL(), getAPIKey())
+	case "export-formats":
+		add(exportFormats...)
+	case "usage-formats":
+		add("table", "csv", "json")
+	case "channels":
+		add(updateChannels...)
+	case "outputs":
+		add(outputText, outputJSON)
+	case "shells":
+		add(completionShells...)
+	case "config-keys":
+		for _, s := range configSettings {
+			items = append(items, completionItem{Value: s.key, Label: s.doc})
+		}
+	case "profiles":
+		if cfg, err := loadConfigFile(); err == nil {
+			names := make([]string, 0, len(cfg.Profiles))
+			for name := range cfg.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			add(names...)
+		}
+	}
+	return items
+}
+
+// workspaceCache is the cached workspace list for one API URL and key.
+type workspaceCache struct {
+	APIURL string           `json:"api_url"`
+	Key    string           `json:"key"` // keyFingerprint of the key that listed them
+	Items  []completionItem `json:"items"`
+}
+
+// cachedWorkspaces returns the workspaces apiKey can see, from the cache when it is
+// fresh. Without a key, or when the API does not answer in time, there are none.
+func cachedWorkspaces(apiBaseURL, apiKey string) []completionItem {
+	if apiKey == "" {
+		return nil
+	}
+	fingerprint := keyFingerprint(apiKey)
+	var cached workspaceCache
+	if readCache(workspaceCacheName, workspaceCacheTTL, &cached) && cached.APIURL == apiBaseURL && cached.Key == fingerprint {
+		return cached.Items
+	}
+	done := make(chan []workspaceResp, 1)
+	go func() {
+		ws, err := listWorkspaces(apiKey, apiBaseURL)
+		if err != nil {
+			ws = nil
+		}
+		done <- ws
+	}()
+	var workspaces []workspaceResp
+	select {
+	case workspaces = <-done:
+	case <-time.After(completionTimeout):
+		return nil
+	}
+	if workspaces == nil {
+		return nil
+	}
+	items := make([]completionItem, 0, len(workspaces))
+	for _, w := range workspaces {
+		items = append(items, completionItem{Value: w.ID, Label: w.Name})
+	}
+	_ = writeCache(workspaceCacheName, workspaceCache{APIURL: apiBaseURL, Key: fingerprint, Items: items})
+	return items
+}
+
+// keyFingerprint identifies an API key in cache files without storing any of it.
+func keyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// --- bash ---
+
+func bashCompletion() string {
+	var b strings.Builder
+	b.WriteString("# bash completion for a21e. Generated by `a21e completion bash`; do not edit.\n\n")
+
+	b.WriteString("_a21e_subcommands() {\n    case \"$1\" in\n")
+	for _, path := range commandPaths() {
+		if subs := subcommands(path); len(subs) > 0 {
+			names := make([]string, len(subs))
+			for i, s := range subs {
+				names[i] = s.name
+			}
+			fmt.Fprintf(&b, "        %q) echo %q ;;\n", path, strings.Join(names, " "))
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("_a21e_flags() {\n    case \"$1\" in\n")
+	for _, c := range completionCommands() {
+		if flags := c.completionFlags(); len(flags) > 0 {
+			opts := make([]string, len(flags))
+			for i, f := range flags {
+				opts[i] = f.option()
+			}
+			fmt.Fprintf(&b, "        %q) echo %q ;;\n", c.path(), strings.Join(opts, " "))
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("_a21e_args() {\n    case \"$1\" in\n")
+	for _, c := range completionCommands() {
+		if c.argKind != "" {
+			fmt.Fprintf(&b, "        %q) echo %q ;;\n", c.path(), c.argKind)
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	byKind := valueFlagsByKind()
+	var valueOpts []string
+	for _, kind := range byKind.sortedKeys() {
+		valueOpts = append(valueOpts, byKind[kind]...)
+	}
+	b.WriteString(`_a21e_values() {
+    local IFS=$'\n'
+    COMPREPLY=($(compgen -W "$(a21e __complete "$1" 2>/dev/null | cut -f1)" -- "$2"))
+}
+
+_a21e() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local path="" w i=1 positional=0
+    while [[ $i -lt $COMP_CWORD ]]; do
+        w="${COMP_WORDS[i]}"
+        case "$w" in
+            ` + strings.Join(valueOpts, "|") + `) ((i += 2)); continue ;;
+            -*) ;;
+            *)
+                if [[ $positional -eq 0 && " $(_a21e_subcommands "$path") " == *" $w "* ]]; then
+                    path="${path:+$path }$w"
+                else
+                    positional=1
+                fi
+                ;;
+        esac
+        ((i++))
+    done
+
+    case "$prev" in
+`)
+	for _, kind := range byKind.sortedKeys() {
+		opts := byKind[kind]
+		switch kind {
+		case "files":
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(opts, "|"))
+		case "":
+			fmt.Fprintf(&b, "        %s) return ;;\n", strings.Join(opts, "|"))
+		default:
+			fmt.Fprintf(&b, "        %s) _a21e_values %s \"$cur\"; return ;;\n", strings.Join(opts, "|"), kind)
+		}
+	}
+	b.WriteString(`    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$(_a21e_flags "$path") ` + strings.Join(globalOptions(), " ") + `" -- "$cur"))
+        return
+    fi
+    local subs
+    subs="$(_a21e_subcommands "$path")"
+    if [[ -n "$subs" && $positional -eq 0 ]]; then
+        COMPREPLY=($(compgen -W "$subs" -- "$cur"))
+        return
+    fi
+    local kind
+    kind="$(_a21e_args "$path")"
+    if [[ -n "$kind" && $positional -eq 0 ]]; then
+        _a21e_values "$kind" "$cur"
+    fi
+}
+
+complete -o default -F _a21e a21e
+`)
+	return b.String()
+}
+
+// valueFlagsByKind groups every value-taking flag of every command (in both -x
+// and --x spellings) by completion kind, in a stable order.
+func valueFlagsByKind() orderedKinds {
+	seen := map[string]bool{}
+	groups := map[string][]string{}
+	all := append([]completionFlag{}, flagsOf(newGlobalFlagSet())...)
+	for _, c := range completionCommands() {
+		all = append(all, c.completionFlags()...)
+	}
+	for _, f := range all {
+		if !f.hasValue || seen[f.name] {
+			continue
+		}
+		seen[f.name] = true
+		groups[f.kind] = append(groups[f.kind], "--"+f.name, "-"+f.name)
+	}
+	groups["outputs"] = append(groups["outputs"], "-o")
+	return orderedKinds(groups)
+}
+
+// globalOptions are the global flags as offered after any command.
+func globalOptions() []string {
+	var opts []string
+	for _, f := range flagsOf(newGlobalFlagSet()) {
+		opts = append(opts, f.option())
+	}
+	return opts
+}
+
+// globalValueOptions are the spellings of global flags that take a value, which
+// the scripts skip (with the value) when working out the command path.
+func globalValueOptions() []string {
+	opts := []string{"-o"}
+	for _, f := range flagsOf(newGlobalFlagSet()) {
+		if f.hasValue {
+			opts = append(opts, "--"+f.name, "-"+f.name)
+		}
+	}
+	return opts
+}
+
+// orderedKinds ranges over kinds in sorted order so generated scripts are stable.
+type orderedKinds map[string][]string
+
+func (o orderedKinds) sortedKeys() []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// --- zsh ---
+
+func zshCompletion() string {
+	var b strings.Builder
+	b.WriteString("#compdef a21e\n# zsh completion for a21e. Generated by `a21e completion zsh`; do not edit.\n\n")
+	byKind := valueFlagsByKind()
+	var valueOpts []string
+	for _, kind := range byKind.sortedKeys() {
+		valueOpts = append(valueOpts, byKind[kind]...)
+	}
+	b.WriteString(`_a21e_values() {
+  local -a items
+  items=("${(@f)$(a21e __complete $1 2>/dev/null)}")
+  items=(${items:#})
+  items=("${(@)items//:/\\:}")
+  items=("${(@)items//	/:}")
+  _describe -t $1 $1 items
+}
+
+`)
+	for _, path := range commandPaths() {
+		writeZshFunction(&b, path)
+	}
+	b.WriteString(`if [ "$funcstack[1]" = "_a21e" ]; then
+  _a21e "$@"
+else
+  compdef _a21e a21e
+fi
+`)
+	return b.String()
+}
+
+func zshFunctionName(path string) string {
+	if path == "" {
+		return "_a21e"
+	}
+	return "_a21e_" + strings.NewReplacer(" ", "_", "-", "_").Replace(path)
+}
+
+func writeZshFunction(b *strings.Builder, path string) {
+	fmt.Fprintf(b, "%s() {\n", zshFunctionName(path))
+	specs := []string{}
+	for _, f := range flagsOf(newGlobalFlagSet()) {
+		specs = append(specs, zshFlagSpec(f))
+	}
+	subs := subcommands(path)
+	if path != "" {
+		c := rootCommand().find(path)
+		for _, f := range c.completionFlags() {
+			specs = append(specs, zshFlagSpec(f))
+		}
+		if c.argKind != "" {
+			specs = append(specs, fmt.Sprintf("'1:%s:_a21e_values %s'", c.argKind, c.argKind))
+		}
+	}
+	if len(subs) == 0 {
+		b.WriteString("  _arguments \\\n")
+		for _, s := range specs {
+			fmt.Fprintf(b, "    %s \\\n", s)
+		}
+		b.WriteString("    '*: :_default'\n}\n\n")
+		return
+	}
+
+	b.WriteString("  local curcontext=\"$curcontext\" state line\n  _arguments -C \\\n")
+	for _, s := range specs {
+		fmt.Fprintf(b, "    %s \\\n", s)
+	}
+	b.WriteString("    '1:command:->command' \\\n    '*::arg:->args'\n")
+	b.WriteString("  case $state in\n    command)\n      local -a commands\n      commands=(\n")
+	for _, s := range subs {
+		fmt.Fprintf(b, "        %s\n", zshQuote(s.name+":"+s.summary))
+	}
+	b.WriteString("      )\n      _describe -t commands command commands\n      ;;\n    args)\n      case $line[1] in\n")
+	for _, s := range subs {
+		fmt.Fprintf(b, "        %s) %s ;;\n", s.name, zshFunctionName(s.path()))
+	}
+	b.WriteString("      esac\n      ;;\n  esac\n}\n\n")
+}
+
+func zshFlagSpec(f completionFlag) string {
+	desc := strings.NewReplacer("[", "(", "]", ")", ": ", " - ", ":", " ").Replace(f.usage)
+	spec := f.option() + "[" + desc + "]"
+	if f.hasValue {
+		switch f.kind {
+		case "files":
+			spec += ":file:_files"
+		case "":
+			spec += ":" + f.name + ": "
+		default:
+			spec += ":" + f.name + ":_a21e_values " + f.kind
+		}
+	}
+	return zshQuote(spec)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// --- fish ---
+
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString("# fish completion for a21e. Generated by `a21e completion fish`; do not edit.\n\n")
+	b.WriteString("function __a21e_subcommands\n    switch \"$argv[1]\"\n")
+	for _, path := range commandPaths() {
+		if subs := subcommands(path); len(subs) > 0 {
+			names := make([]string, len(subs))
+			for i, s := range subs {
+				names[i] = s.name
+			}
+			fmt.Fprintf(&b, "        case %s\n            printf '%%s\\n' %s\n", fishQuote(path), strings.Join(names, " "))
+		}
+	}
+	b.WriteString("    end\nend\n\n")
+	b.WriteString(`function __a21e_path
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l path
+    set -l skip 0
+    for t in $tokens
+        if test $skip -eq 1
+            set skip 0
+            continue
+        end
+        switch $t
+            case ` + strings.Join(globalValueOptions(), " ") + `
+                set skip 1
+                continue
+            case '-*'
+                continue
+        end
+        if contains -- $t (__a21e_subcommands "$path")
+            set path $path $t
+        else
+            break
+        end
+    end
+    string join ' ' -- $path
+end
+
+function __a21e_path_is
+    test (__a21e_path) = "$argv[1]"
+end
+
+function __a21e_values
+    a21e __complete $argv[1] 2>/dev/null
+end
+
+complete -c a21e -f
+`)
+	for _, f := range flagsOf(newGlobalFlagSet()) {
+		b.WriteString(fishFlagLine("", f, false))
+	}
+	b.WriteString("complete -c a21e -s o -r -a '(__a21e_values outputs)' -d 'Output format: json or text'\n")
+	for _, path := range commandPaths() {
+		cond := "__a21e_path_is " + fishQuote(path)
+		for _, s := range subcommands(path) {
+			fmt.Fprintf(&b, "complete -c a21e -n %s -a %s -d %s\n", fishQuote(cond), fishQuote(s.name), fishQuote(s.summary))
+		}
+		if path == "" {
+			continue
+		}
+		c := rootCommand().find(path)
+		for _, f := range c.completionFlags() {
+			b.WriteString(fishFlagLine(cond, f, true))
+		}
+		if c.argKind != "" {
+			fmt.Fprintf(&b, "complete -c a21e -n %s -a %s\n", fishQuote(cond), fishQuote("(__a21e_values "+c.argKind+")"))
+		}
+	}
+	return b.String()
+}
+
+func fishFlagLine(cond string, f completionFlag, withCond bool) string {
+	var b strings.Builder
+	b.WriteString("complete -c a21e")
+	if withCond {
+		b.WriteString(" -n " + fishQuote(cond))
+	}
+	if len(f.name) == 1 {
+		b.WriteString(" -o " + f.name)
+	} else {
+		b.WriteString(" -l " + f.name)
+	}
+	if f.hasValue {
+		switch f.kind {
+		case "files":
+			b.WriteString(" -r -F")
+		case "":
+			b.WriteString(" -r")
+		default:
+			b.WriteString(" -r -a " + fishQuote("(__a21e_values "+f.kind+")"))
+		}
+	}
+	b.WriteString(" -d " + fishQuote(f.usage) + "\n")
+	return b.String()
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompletionScriptsCoverEveryFlag(t *testing.T) {
+	t.Parallel()
+	for _, shell := range completionShells {
+		var b strings.Builder
+		if err := writeCompletionScript(&b, shell); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		for _, c := range completionCommands() {
+			if !strings.Contains(script, c.name) {
+				t.Errorf("%s script is missing command %q", shell, c.path())
+			}
+			for _, f := range c.completionFlags() {
+				want := f.option()
+				if shell == "fish" {
+					want = map[bool]string{true: "-o ", false: "-l "}[len(f.name) == 1] + f.name
+				}
+				if !strings.Contains(script, want) {
+					t.Errorf("%s script is missing %s for %q", shell, want, c.path())
+				}
+			}
+		}
+		if _, err := exec.LookPath(shell); err == nil {
+			path := filepath.Join(t.TempDir(), "a21e."+shell)
+			os.WriteFile(path, []byte(script), 0o600)
+			if out, err := exec.Command(shell, "-n", path).CombinedOutput(); err != nil {
+				t.Errorf("%s -n: %v\n%s", shell, err, out)
+			}
+		}
+	}
+	if err := writeCompletionScript(&strings.Builder{}, "powershell"); exitCodeFor(err) != exitValidation {
+		t.Fatalf("unsupported shell error = %v", err)
+	}
+}
+
+func TestInstallCompletionIsIdempotent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/zsh")
+	t.Setenv("A21E_SHELL_RC", "")
+	zshrc := filepath.Join(home, ".zshrc")
+	os.WriteFile(zshrc, []byte("export EDITOR=vim\n"), 0o600)
+
+	for i, wantChanged := range []bool{true, false} {
+		res, err := installCompletion("zsh", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path != zshrc || res.Changed != wantChanged {
+			t.Fatalf("install #%d = %+v", i+1, res)
+		}
+	}
+	raw, _ := os.ReadFile(zshrc)
+	if !strings.HasPrefix(string(raw), "export EDITOR=vim\n") || strings.Count(string(raw), completionBlockStart) != 1 ||
+		!strings.Contains(string(raw), "source <(a21e completion zsh)") {
+		t.Fatalf(".zshrc =\n%s", raw)
+	}
+
+	res, err := installCompletion("fish", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(home, ".config", "fish", "completions", "a21e.fish"),
+		completionBlockStart+"\na21e completion fish | source\n"+completionBlockEnd+"\n")
+	if !res.Changed {
+		t.Fatalf("fish install = %+v", res)
+	}
+}
+
+func TestCachedWorkspaces(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/workspaces" {
+			http.NotFound(w, r)
+			return
+		}
+		hits.Add(1)
+		w.Write([]byte(`{"items":[{"id":"ws_1","name":"Platform"},{"id":"ws_2","name":"Research"}]}`))
+	}))
+	defer srv.Close()
+
+	want := []completionItem{{Value: "ws_1", Label: "Platform"}, {Value: "ws_2", Label: "Research"}}
+	for i := 0; i < 2; i++ {
+		got := cachedWorkspaces(srv.URL, "a21e_key")
+		if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("workspaces = %+v", got)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("API hit %d times, want 1 (second lookup should be cached)", n)
+	}
+	if got := cachedWorkspaces(srv.URL, "a21e_other_key"); len(got) != 2 || hits.Load() != 2 {
+		t.Fatalf("another key got %+v after %d API hits; want a fresh lookup", got, hits.Load())
+	}
+	if got := cachedWorkspaces(srv.URL, ""); got != nil {
+		t.Fatalf("lookup without a key returned %+v", got)
+	}
+	if got := cachedWorkspaces("https://other.example.invalid", ""); got != nil {
+		t.Fatalf("another API URL without a key returned %+v", got)
+	}
+}
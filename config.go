@@ -1,16 +1,27 @@
+// config.go — Credentials and settings (~/.a21e/credentials, ~/.a21e/config.toml).
+
 package main
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func getAPIKey() string {
 	if k := os.Getenv("A21E_API_KEY"); k != "" {
 		return k
 	}
+	return readCredentialsFile()
+}
+
+// readCredentialsFile returns the key saved in ~/.a21e/credentials, ignoring A21E_API_KEY.
+func readCredentialsFile() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return ""
@@ -31,9 +42,225 @@ func getAPIKey() string {
 }
 
 func getAPIBaseURL() string {
-	u := os.Getenv("A21E_API_URL")
-	if u != "" {
-		return u
+	return settingValue("api_url")
+}
+
+// Settings resolve in this order: command-line flags (global ones such as --api-url
+// via flagSettings, the rest applied by each command), environment variables, the
+// active profile in ~/.a21e/config.toml, the top level of that file, then built-in
+// defaults. The profile is chosen with --profile or A21E_PROFILE.
+
+const configHeader = "# a21e CLI configuration. Edit with `a21e config set|unset`; see `a21e config list`.\n\n"
+
+// configSetting is one documented key of config.toml.
+type configSetting struct {
+	key      string
+	env      string
+	builtin  string
+	doc      string
+	validate func(string) error
+}
+
+var configSettings = []configSetting{
+	{key: "api_url", env: "A21E_API_URL", builtin: defaultAPIURL, doc: "API base URL", validate: validateConfigURL},
+	{key: "discovery_hosts", env: "A21E_DISCOVERY_HOSTS", doc: "Comma-separated hosts, besides api_url's, that discovered endpoints may point at"},
+	{key: "model", env: "A21E_DEFAULT_MODEL", builtin: defaultModel, doc: "Model written into tool configuration when --model is not given"},
+	{key: "scope", env: "A21E_SCOPE", builtin: "user", doc: "Scope of keys created by init: user or workspace", validate: validateConfigScope},
+	{key: "device_poll_interval", env: "A21E_DEVICE_POLL_INTERVAL", builtin: "2s", doc: "How often device login polls for approval", validate: validateConfigDuration},
+	{key: "device_timeout", env: "A21E_DEVICE_TIMEOUT", builtin: "5m", doc: "How long device login waits for approval", validate: validateConfigDuration},
+	{key: "shell_rc", env: "A21E_SHELL_RC", doc: "Shell profile --apply writes to (default: chosen from $SHELL)"},
+	{key: "ca_bundle", env: "A21E_CA_BUNDLE", doc: "PEM file of extra root certificates to trust (e.g. a TLS-inspecting proxy)"},
+	{key: "client_cert", env: "A21E_CLIENT_CERT", doc: "PEM client certificate for mTLS"},
+	{key: "client_key", env: "A21E_CLIENT_KEY", doc: "PEM private key for client_cert"},
+	{key: "release_url", env: "A21E_RELEASE_URL", builtin: "https://get.a21e.com/releases", doc: "Where self-update looks for releases", validate: validateConfigURL},
+	{key: "update_channel", env: "A21E_UPDATE_CHANNEL", builtin: "stable", doc: "Release channel for self-update: stable or beta", validate: validateUpdateChannel},
+	{key: "update_check", env: "A21E_UPDATE_CHECK", builtin: "true", doc: "Whether a21e version checks (once a day) for a newer release", validate: validateConfigBool},
+}
+
+func lookupConfigSetting(key string) (configSetting, bool) {
+	for _, s := range configSettings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return configSetting{}, false
+}
+
+func configKeys() []string {
+	keys := make([]string, 0, len(configSettings))
+	for _, s := range configSettings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// configFile is the parsed config.toml: top-level values and [profiles.<name>] tables.
+type configFile struct {
+	Values   map[string]string
+	Profiles map[string]map[string]string
+}
+
+func configFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".a21e", "config.toml"), nil
+}
+
+// loadConfigFile reads config.toml; a missing file is an empty config.
+func loadConfigFile() (*configFile, error) {
+	cfg := &configFile{Values: map[string]string{}, Profiles: map[string]map[string]string{}}
+	path, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	tables, err := parseTOML(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, values := range tables {
+		switch {
+		case name == "":
+			cfg.Values = values
+		case strings.HasPrefix(name, "profiles.") && !strings.Contains(strings.TrimPrefix(name, "profiles."), "."):
+			cfg.Profiles[strings.TrimPrefix(name, "profiles.")] = values
+		case name == "profiles":
+		default:
+			return nil, fmt.Errorf("%s: unknown table [%s] (want [profiles.<name>])", path, name)
+		}
+	}
+	return cfg, nil
+}
+
+func (c *configFile) save() error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create config directory: %w", err)
+	}
+	tables := tomlTables{"": c.Values}
+	for name, values := range c.Profiles {
+		tables["profiles."+name] = values
+	}
+	return writeFileAtomic(path, renderTOML(configHeader, tables, configKeys()), 0o600, nil)
+}
+
+// profileOverride is the global --profile flag ("" when not given).
+var profileOverride string
+
+// activeProfile is the config profile in effect ("" for none).
+func activeProfile() string {
+	if profileOverride != "" {
+		return profileOverride
+	}
+	return os.Getenv("A21E_PROFILE")
+}
+
+// flagSetting is a setting given by a global flag for this run.
+type flagSetting struct {
+	flag  string
+	value string
+}
+
+var flagSettings = map[string]flagSetting{}
+
+// resolveSetting returns the effective value of key and where it came from.
+func resolveSetting(cfg *configFile, key string) (value, source string) {
+	s, _ := lookupConfigSetting(key)
+	if f, ok := flagSettings[key]; ok {
+		return f.value, "flag " + f.flag
+	}
+	if v := os.Getenv(s.env); v != "" {
+		return v, "env " + s.env
+	}
+	if p := activeProfile(); p != "" {
+		if v, ok := cfg.Profiles[p][key]; ok {
+			return v, "profile " + p
+		}
+	}
+	if v, ok := cfg.Values[key]; ok {
+		return v, "config file"
+	}
+	return s.builtin, "built-in"
+}
+
+var configWarned bool
+
+// settingValue returns the effective value of key for commands that have not been
+// given a flag for it. A config file that cannot be read is reported once and
+// ignored, so a typo there never blocks commands like `a21e config set`.
+func settingValue(key string) string {
+	cfg, err := loadConfigFile()
+	if err != nil {
+		if !configWarned {
+			fmt.Fprintf(os.Stderr, "a21e: ignoring config file: %v\n", err)
+			configWarned = true
+		}
+		cfg = &configFile{}
+	}
+	v, _ := resolveSetting(cfg, key)
+	return v
+}
+
+// expandHome expands a leading ~/ in a path setting.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// durationSetting parses a duration setting, falling back to the built-in default.
+func durationSetting(key string) time.Duration {
+	if d, err := time.ParseDuration(settingValue(key)); err == nil && d > 0 {
+		return d
+	}
+	s, _ := lookupConfigSetting(key)
+	d, _ := time.ParseDuration(s.builtin)
+	return d
+}
+
+func validateConfigURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", v)
+	}
+	return nil
+}
+
+func validateConfigScope(v string) error {
+	if !isValidScope(v) {
+		return fmt.Errorf("invalid scope %q (want user or workspace)", v)
+	}
+	return nil
+}
+
+func validateConfigBool(v string) error {
+	if v != "true" && v != "false" {
+		return fmt.Errorf("%q is not true or false", v)
+	}
+	return nil
+}
+
+func validateConfigDuration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("%q is not a positive duration (e.g. 2s, 5m)", v)
 	}
-	return "https://api.a21e.com"
+	return nil
 }
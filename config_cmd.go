@@ -0,0 +1,183 @@
+// config_cmd.go — `a21e config get|set|unset|list` for ~/.a21e/config.toml.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// configEntry is one setting as `config get` and `config list` report it.
+type configEntry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env"`
+	Doc    string `json:"description"`
+}
+
+type configListOutput struct {
+	Path     string        `json:"path"`
+	Profile  string        `json:"profile,omitempty"`
+	Settings []configEntry `json:"settings"`
+}
+
+// newConfigFlagSet defines the flags of config set and unset: --profile names the
+// profile to change. A global --profile given before the command is the default, so
+// `a21e --profile eu config set ...` changes eu. (config get uses the global flag.)
+func newConfigFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("config "+name, flag.ContinueOnError)
+	return fs, fs.String("profile", profileOverride, "Profile to change instead of the top level")
+}
+
+// parseConfigArgs parses --profile and expects exactly n positional arguments.
+func parseConfigArgs(name string, args []string, n int) (profile string, rest []string) {
+	fs, p := newConfigFlagSet(name)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	// Allow --profile after the positional arguments too.
+	rest = fs.Args()
+	if len(rest) > n {
+		if err := fs.Parse(rest[n:]); err != nil {
+			os.Exit(exitValidation)
+		}
+		if fs.NArg() > 0 {
+			exitWithError("config", withExitCode(exitValidation, fmt.Errorf("unexpected argument %q", fs.Arg(0))))
+		}
+		rest = rest[:n]
+	}
+	if len(rest) != n {
+		exitWithError("config "+name, usageError("config "+name))
+	}
+	if _, ok := lookupConfigSetting(rest[0]); !ok {
+		exitWithError("config", withExitCode(exitValidation, fmt.Errorf("unknown setting %q. Settings: %s", rest[0], strings.Join(configKeys(), ", "))))
+	}
+	return *p, rest
+}
+
+func mustLoadConfig() *configFile {
+	cfg, err := loadConfigFile()
+	if err != nil {
+		exitWithError("config", withExitCode(exitValidation, err))
+	}
+	return cfg
+}
+
+func configEntryFor(cfg *configFile, s configSetting) configEntry {
+	value, source := resolveSetting(cfg, s.key)
+	return configEntry{Key: s.key, Value: value, Source: source, Env: s.env, Doc: s.doc}
+}
+
+func runConfigGet(args []string) {
+	_, rest := parseConfigArgs("get", args, 1)
+	s, _ := lookupConfigSetting(rest[0])
+	entry := configEntryFor(mustLoadConfig(), s)
+	if jsonOutput() {
+		writeJSON(entry)
+		return
+	}
+	fmt.Println(entry.Value)
+}
+
+func runConfigSet(args []string) {
+	profile, rest := parseConfigArgs("set", args, 2)
+	s, _ := lookupConfigSetting(rest[0])
+	value := rest[1]
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			exitWithError("config", withExitCode(exitValidation, fmt.Errorf("%s: %w", s.key, err)))
+		}
+	}
+	cfg := mustLoadConfig()
+	values := cfg.Values
+	if profile != "" {
+		if cfg.Profiles[profile] == nil {
+			cfg.Profiles[profile] = map[string]string{}
+		}
+		values = cfg.Profiles[profile]
+	}
+	values[s.key] = value
+	if err := cfg.save(); err != nil {
+		exitWithError("config", err)
+	}
+	reportConfigChange(cfg, s, profile)
+}
+
+func runConfigUnset(args []string) {
+	profile, rest := parseConfigArgs("unset", args, 1)
+	s, _ := lookupConfigSetting(rest[0])
+	cfg := mustLoadConfig()
+	values := cfg.Values
+	if profile != "" {
+		values = cfg.Profiles[profile]
+	}
+	if _, ok := values[s.key]; ok {
+		delete(values, s.key)
+		if err := cfg.save(); err != nil {
+			exitWithError("config", err)
+		}
+	}
+	reportConfigChange(cfg, s, profile)
+}
+
+// reportConfigChange shows the effective value after set/unset, and warns when a
+// higher-precedence source still overrides the file.
+func reportConfigChange(cfg *configFile, s configSetting, profile string) {
+	entry := configEntryFor(cfg, s)
+	if jsonOutput() {
+		writeJSON(entry)
+		return
+	}
+	where := "config file"
+	if profile != "" {
+		where = "profile " + profile
+	}
+	fmt.Fprintf(os.Stderr, "Updated %s in %s.\n", s.key, where)
+	fmt.Fprintf(os.Stderr, "Effective value: %q (from %s)\n", entry.Value, entry.Source)
+	if profile != "" && profile != activeProfile() {
+		fmt.Fprintf(os.Stderr, "Note: profile %s is not active; pass --profile %s or set A21E_PROFILE=%s to use it.\n", profile, profile, profile)
+	}
+	if strings.HasPrefix(entry.Source, "env ") {
+		fmt.Fprintf(os.Stderr, "Note: %s is set in your environment and takes precedence.\n", s.env)
+	}
+}
+
+func runConfigList(args []string) {
+	if len(args) > 0 {
+		exitWithError("config list", usageError("config list"))
+	}
+	cfg := mustLoadConfig()
+	path, _ := configFilePath()
+	out := configListOutput{Path: path, Profile: activeProfile()}
+	for _, s := range configSettings {
+		out.Settings = append(out.Settings, configEntryFor(cfg, s))
+	}
+	if jsonOutput() {
+		writeJSON(out)
+		return
+	}
+	fmt.Printf("Config file: %s\n", out.Path)
+	if out.Profile != "" {
+		fmt.Printf("Profile:     %s", out.Profile)
+		if _, ok := cfg.Profiles[out.Profile]; !ok {
+			fmt.Print(" (not defined in the config file)")
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	fmt.Printf("%-22s %-28s %s\n", "KEY", "VALUE", "SOURCE")
+	for _, e := range out.Settings {
+		value := e.Value
+		if value == "" {
+			value = `""`
+		}
+		fmt.Printf("%-22s %-28s %s\n", e.Key, value, e.Source)
+	}
+}
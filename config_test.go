@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSettingPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, s := range configSettings {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv("A21E_PROFILE", "")
+
+	cfg := &configFile{
+		Values:   map[string]string{"api_url": "https://file.example", "model": "gpt-4o", "device_timeout": "90s"},
+		Profiles: map[string]map[string]string{"eu": {"api_url": "https://eu.example"}},
+	}
+	if err := cfg.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(key, wantValue, wantSource string) {
+		t.Helper()
+		loaded, err := loadConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, src := resolveSetting(loaded, key)
+		if v != wantValue || src != wantSource {
+			t.Errorf("%s = %q from %q, want %q from %q", key, v, src, wantValue, wantSource)
+		}
+	}
+
+	check("scope", "user", "built-in")
+	check("api_url", "https://file.example", "config file")
+	t.Setenv("A21E_PROFILE", "eu")
+	check("api_url", "https://eu.example", "profile eu")
+	check("model", "gpt-4o", "config file") // not set in the profile
+	t.Setenv("A21E_API_URL", "https://env.example")
+	check("api_url", "https://env.example", "env A21E_API_URL")
+
+	if got := getAPIBaseURL(); got != "https://env.example" {
+		t.Errorf("getAPIBaseURL = %q", got)
+	}
+	if got := durationSetting("device_timeout"); got != 90*time.Second {
+		t.Errorf("device_timeout = %v, want 90s", got)
+	}
+	t.Setenv("A21E_DEVICE_POLL_INTERVAL", "soon")
+	if got := durationSetting("device_poll_interval"); got != 2*time.Second {
+		t.Errorf("invalid duration should fall back to the built-in 2s, got %v", got)
+	}
+
+	t.Setenv("A21E_SHELL_RC", "~/.config/zsh/zshrc")
+	if got, _ := resolveShellRCPath(); got != filepath.Join(home, ".config/zsh/zshrc") {
+		t.Errorf("resolveShellRCPath = %q", got)
+	}
+
+	info, err := os.Stat(filepath.Join(home, ".a21e", "config.toml"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("config.toml mode = %v, %v; want 0600", info, err)
+	}
+}
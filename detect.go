@@ -6,35 +6,251 @@
 //
 // Detection order:
 //   - A21E_TOOL_ID: explicit override (CI or user)
-//   - TERM_PROGRAM=cursor → cursor (Cursor may set this in future; currently Cursor often sets vscode)
-//   - TERM_PROGRAM=vscode → vscode (VS Code and sometimes Cursor)
-//   - TERMINAL_EMULATOR containing "JetBrains" → jetbrains (IntelliJ, PyCharm, etc.)
+//   - CLI agents that run shell commands (Claude Code, Codex, Aider), because they are
+//     usually started from an editor terminal and should win over the editor:
+//       - an env marker the agent sets for its child processes (CLAUDECODE, CODEX_SANDBOX, …)
+//       - an agent process among our ancestors in /proc (claude, codex, aider; also
+//         "node …/codex.js" or "python …/aider")
+//   - VS Code family (Cursor, Windsurf, VS Code Insiders and VS Code all set
+//     TERM_PROGRAM=vscode), strongest signal first:
+//       - CURSOR_TRACE_ID set → cursor
+//       - VSCODE_GIT_ASKPASS_NODE path names the app (Cursor.app, .windsurf-server, code-insiders, …)
+//       - a parent process in /proc is cursor, windsurf, code-insiders or code
+//       - VSCODE_IPC_HOOK_CLI socket path names a fork (every app uses vscode-ipc-*.sock,
+//         so it only helps when a fork-specific directory holds it)
+//   - each Tool's Detect in toolRegistry order, e.g.
+//       - TERM_PROGRAM=cursor → cursor
+//       - TERM_PROGRAM=vscode → vscode (low confidence: every fork sets it)
+//       - TERMINAL_EMULATOR containing "JetBrains" → jetbrains (IntelliJ, PyCharm, etc.)
 //
-// codex_cli, claude_code_cli, openai_cli_custom have no standard terminal env;
-// use --tool or A21E_TOOL_ID for those.
+// Other OpenAI-compatible CLIs have no standard marker; use --tool openai_cli_custom
+// or A21E_TOOL_ID for those.
 
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
+// Detection confidence levels, strongest first.
+const (
+	confidenceExplicit = "explicit"
+	confidenceHigh     = "high"
+	confidenceMedium   = "medium"
+	confidenceLow      = "low"
+)
+
+// detection is the outcome of tool detection: which tool, how sure, and why.
+type detection struct {
+	ToolID     string `json:"tool_id"`
+	Confidence string `json:"confidence"`
+	Signal     string `json:"signal"`
+}
+
+// detectEnv is everything detection reads, so tests can fake it.
+type detectEnv struct {
+	getenv   func(string) string
+	procRoot string
+	pid      int
+}
+
+func systemDetectEnv() detectEnv {
+	return detectEnv{getenv: os.Getenv, procRoot: "/proc", pid: os.Getpid()}
+}
+
 func detectToolFromEnvironment() string {
-	if v := os.Getenv("A21E_TOOL_ID"); v != "" {
+	if d := detectTool(systemDetectEnv()); d != nil {
+		return d.ToolID
+	}
+	return ""
+}
+
+// detectTool returns the most likely host tool, or nil when nothing matched.
+func detectTool(env detectEnv) *detection {
+	if v := env.getenv("A21E_TOOL_ID"); v != "" {
 		v = strings.TrimSpace(strings.ToLower(v))
 		if isValidToolID(v) {
-			return v
+			return &detection{ToolID: v, Confidence: confidenceExplicit, Signal: "A21E_TOOL_ID=" + v}
 		}
 	}
-	switch os.Getenv("TERM_PROGRAM") {
-	case "cursor":
+	if d := detectCLIAgent(env); d != nil {
+		return d
+	}
+	if d := detectVSCodeFamily(env); d != nil {
+		return d
+	}
+	for _, t := range toolRegistry {
+		if d := t.Detect(env.getenv); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// cliAgent describes how to recognise a CLI coding agent that runs shell commands.
+// Tools carry one in their toolDoc; see toolRegistry.
+type cliAgent struct {
+	name         string
+	envMarkers   []string // set by the agent for the commands it runs
+	processNames []string // comm or script base name, without extension
+}
+
+// detectCLIAgent looks for a CLI agent by env marker first, then in the process chain.
+func detectCLIAgent(env detectEnv) *detection {
+	for _, t := range toolRegistry {
+		if a := t.Doc().Agent; a != nil {
+			for _, marker := range a.envMarkers {
+				if env.getenv(marker) != "" {
+					return &detection{ToolID: t.ID(), Confidence: confidenceHigh, Signal: marker + " is set (" + a.name + ")"}
+				}
+			}
+		}
+	}
+	if env.procRoot == "" {
+		return nil
+	}
+	for _, p := range procAncestors(env.procRoot, env.pid) {
+		names := agentProcessNames(p)
+		for _, t := range toolRegistry {
+			a := t.Doc().Agent
+			if a == nil {
+				continue
+			}
+			for _, want := range a.processNames {
+				for _, name := range names {
+					if name == want {
+						return &detection{
+							ToolID:     t.ID(),
+							Confidence: confidenceMedium,
+							Signal:     fmt.Sprintf("parent process %d (%s) is %s", p.PID, p.Comm, a.name),
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// agentProcessNames returns the names a process may be known by. Agents distributed
+// as scripts run under an interpreter, so for node/python/bun/deno the script name
+// (without extension) counts as well.
+func agentProcessNames(p procInfo) []string {
+	names := p.names()
+	if len(p.Cmdline) < 2 {
+		return names
+	}
+	interp := strings.ToLower(filepath.Base(p.Cmdline[0]))
+	if interp == "node" || interp == "bun" || interp == "deno" || strings.HasPrefix(interp, "python") {
+		script := strings.ToLower(filepath.Base(p.Cmdline[1]))
+		names = append(names, strings.TrimSuffix(script, filepath.Ext(script)))
+	}
+	return names
+}
+
+// detectVSCodeFamily tells VS Code forks apart. They all report TERM_PROGRAM=vscode,
+// so we look at paths and processes that carry the real application name.
+func detectVSCodeFamily(env detectEnv) *detection {
+	if env.getenv("CURSOR_TRACE_ID") != "" {
+		return &detection{ToolID: "cursor", Confidence: confidenceHigh, Signal: "CURSOR_TRACE_ID is set"}
+	}
+	if p := env.getenv("VSCODE_GIT_ASKPASS_NODE"); p != "" {
+		if id := vscodeFamilyFromPath(p); id != "" {
+			return &detection{ToolID: id, Confidence: confidenceHigh, Signal: "VSCODE_GIT_ASKPASS_NODE=" + p}
+		}
+	}
+	inVSCodeTerminal := env.getenv("TERM_PROGRAM") == "vscode" ||
+		env.getenv("VSCODE_IPC_HOOK_CLI") != "" ||
+		env.getenv("VSCODE_GIT_ASKPASS_NODE") != ""
+	if !inVSCodeTerminal {
+		return nil
+	}
+	if d := vscodeFamilyFromProcesses(env); d != nil {
+		return d
+	}
+	// Every fork names its socket vscode-ipc-*.sock, so the hook only helps when
+	// its path names a fork (e.g. a fork-specific remote server directory).
+	if p := env.getenv("VSCODE_IPC_HOOK_CLI"); p != "" {
+		if id := vscodeFamilyFromPath(p); id != "" && id != "vscode" {
+			return &detection{ToolID: id, Confidence: confidenceMedium, Signal: "VSCODE_IPC_HOOK_CLI=" + p}
+		}
+	}
+	return nil
+}
+
+// vscodeFamilyFromProcesses looks for a VS Code fork among the parent processes.
+func vscodeFamilyFromProcesses(env detectEnv) *detection {
+	if env.procRoot == "" {
+		return nil
+	}
+	for _, p := range procAncestors(env.procRoot, env.pid) {
+		for _, name := range p.names() {
+			if id := vscodeFamilyFromProcess(name); id != "" {
+				return &detection{
+					ToolID:     id,
+					Confidence: confidenceMedium,
+					Signal:     fmt.Sprintf("parent process %d (%s)", p.PID, p.Comm),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// vscodeFamilyPathParts maps the path components that identify a VS Code–family
+// app: macOS bundles, remote server directories and executable names (lowercased,
+// without .exe or a " helper …" suffix).
+var vscodeFamilyPathParts = map[string]string{
+	"cursor.app":                        "cursor",
+	".cursor-server":                    "cursor",
+	"cursor":                            "cursor",
+	"windsurf.app":                      "windsurf",
+	".windsurf-server":                  "windsurf",
+	"windsurf":                          "windsurf",
+	"visual studio code - insiders.app": "vscode_insiders",
+	".vscode-server-insiders":           "vscode_insiders",
+	"code - insiders":                   "vscode_insiders",
+	"code-insiders":                     "vscode_insiders",
+	"visual studio code.app":            "vscode",
+	".vscode-server":                    "vscode",
+	"code":                              "vscode",
+}
+
+// vscodeFamilyFromPath maps an application or server path to a tool ID, e.g.
+// /Applications/Cursor.app/… or ~/.vscode-server-insiders/…. Only whole bundle or
+// server directory names and the executable name count, so a home or project
+// directory such as /home/cursor-dev is not mistaken for the app.
+func vscodeFamilyFromPath(p string) string {
+	parts := strings.FieldsFunc(strings.ToLower(p), func(r rune) bool { return r == '/' || r == '\\' })
+	if len(parts) == 0 {
+		return ""
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if strings.HasSuffix(part, ".app") || strings.HasPrefix(part, ".") {
+			if id := vscodeFamilyPathParts[part]; id != "" {
+				return id
+			}
+		}
+	}
+	exe := strings.TrimSuffix(parts[len(parts)-1], ".exe")
+	exe, _, _ = strings.Cut(exe, " helper")
+	return vscodeFamilyPathParts[exe]
+}
+
+// vscodeFamilyFromProcess maps a process name (comm or executable base name) to a tool ID.
+func vscodeFamilyFromProcess(name string) string {
+	switch {
+	case strings.HasPrefix(name, "cursor"):
 		return "cursor"
-	case "vscode":
+	case strings.HasPrefix(name, "windsurf"):
+		return "windsurf"
+	case strings.HasPrefix(name, "code-insiders"), strings.HasPrefix(name, "code - insiders"):
+		return "vscode_insiders"
+	case name == "code", strings.HasPrefix(name, "code helper"):
 		return "vscode"
 	}
-	if strings.Contains(os.Getenv("TERMINAL_EMULATOR"), "JetBrains") {
-		return "jetbrains"
-	}
 	return ""
 }
@@ -0,0 +1,189 @@
+// detect_cmd.go — "a21e detect": explain what tool detection saw and decided.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type detectSignal struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Note  string `json:"note,omitempty"`
+}
+
+type installedTool struct {
+	ToolID   string `json:"tool_id"`
+	Evidence string `json:"evidence"`
+}
+
+// detectProcess is a parent process as shown in the report. Arguments are left out
+// because command lines can carry secrets and the report is meant to be shared.
+type detectProcess struct {
+	PID        int    `json:"pid"`
+	Comm       string `json:"comm"`
+	Executable string `json:"executable,omitempty"`
+}
+
+type detectReport struct {
+	Signals        []detectSignal  `json:"signals"`
+	Processes      []detectProcess `json:"processes,omitempty"`
+	Installed      []installedTool `json:"installed"`
+	Decision       *detection      `json:"decision"`
+	AutoApplicable []string        `json:"auto_applicable"`
+}
+
+func newDetectFlagSet() (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	return fs, fs.Bool("json", false, "Print the report as JSON (same as --output json)")
+}
+
+func runDetect(args []string) {
+	fs, asJSON := newDetectFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *asJSON {
+		outputMode = outputJSON
+	}
+
+	report := buildDetectReport(systemDetectEnv(), exec.LookPath)
+	if jsonOutput() {
+		writeJSON(report)
+		return
+	}
+	printDetectReport(report)
+}
+
+// buildDetectReport gathers every input detectTool reads, plus what is installed.
+func buildDetectReport(env detectEnv, lookPath func(string) (string, error)) *detectReport {
+	report := &detectReport{
+		Decision:       detectTool(env),
+		Installed:      []installedTool{},
+		AutoApplicable: []string{},
+	}
+
+	override := env.getenv("A21E_TOOL_ID")
+	overrideNote := ""
+	switch normalized := strings.TrimSpace(strings.ToLower(override)); {
+	case override == "":
+	case isValidToolID(normalized):
+		overrideNote = "valid"
+	default:
+		overrideNote = "ignored: not a supported tool_id"
+	}
+	report.Signals = append(report.Signals, detectSignal{Name: "A21E_TOOL_ID", Value: override, Note: overrideNote})
+	for _, name := range detectEnvVars() {
+		report.Signals = append(report.Signals, detectSignal{Name: name, Value: env.getenv(name)})
+	}
+	if env.procRoot != "" {
+		for _, p := range procAncestors(env.procRoot, env.pid) {
+			dp := detectProcess{PID: p.PID, Comm: p.Comm}
+			if len(p.Cmdline) > 0 {
+				dp.Executable = p.Cmdline[0]
+			}
+			report.Processes = append(report.Processes, dp)
+		}
+	}
+
+	for _, t := range toolRegistry {
+		installed := findInstalledTool(t, lookPath)
+		if installed != nil {
+			report.Installed = append(report.Installed, *installed)
+		}
+		if t.Doc().AutoApply == "" {
+			continue
+		}
+		if _, err := t.Target(); err != nil {
+			continue
+		}
+		// Editors only count when present; the shell profile is always there to patch.
+		if _, isEditor := t.(*editorTool); isEditor && installed == nil {
+			continue
+		}
+		report.AutoApplicable = append(report.AutoApplicable, t.ID())
+	}
+	return report
+}
+
+// detectEnvVars lists the environment variables detection reads, besides A21E_TOOL_ID.
+func detectEnvVars() []string {
+	names := []string{
+		"TERM_PROGRAM", "TERM_PROGRAM_VERSION", "TERMINAL_EMULATOR",
+		"CURSOR_TRACE_ID", "VSCODE_GIT_ASKPASS_NODE", "VSCODE_IPC_HOOK_CLI",
+	}
+	for _, t := range toolRegistry {
+		if a := t.Doc().Agent; a != nil {
+			names = append(names, a.envMarkers...)
+		}
+	}
+	return names
+}
+
+// findInstalledTool looks for the tool's executables on PATH and, for editors,
+// its user settings directory.
+func findInstalledTool(t Tool, lookPath func(string) (string, error)) *installedTool {
+	for _, name := range t.Doc().Executables {
+		if p, err := lookPath(name); err == nil {
+			return &installedTool{ToolID: t.ID(), Evidence: p}
+		}
+	}
+	if _, isEditor := t.(*editorTool); isEditor {
+		if target, err := t.Target(); err == nil {
+			if info, err := os.Stat(filepath.Dir(target)); err == nil && info.IsDir() {
+				return &installedTool{ToolID: t.ID(), Evidence: filepath.Dir(target)}
+			}
+		}
+	}
+	return nil
+}
+
+func printDetectReport(r *detectReport) {
+	fmt.Println("Signals:")
+	for _, s := range r.Signals {
+		value := s.Value
+		if value == "" {
+			value = "(unset)"
+		}
+		if s.Note != "" {
+			value += "  [" + s.Note + "]"
+		}
+		fmt.Printf("  %-32s %s\n", s.Name, value)
+	}
+
+	if len(r.Processes) > 0 {
+		fmt.Println("\nParent processes (nearest first):")
+		for _, p := range r.Processes {
+			fmt.Printf("  %-8d %-16s %s\n", p.PID, p.Comm, p.Executable)
+		}
+	}
+
+	fmt.Println("\nInstalled tools:")
+	if len(r.Installed) == 0 {
+		fmt.Println("  (none found)")
+	}
+	for _, it := range r.Installed {
+		fmt.Printf("  %-18s %s\n", it.ToolID, it.Evidence)
+	}
+
+	fmt.Println()
+	if r.Decision == nil {
+		fmt.Println("Decision: no tool detected (use --tool or set A21E_TOOL_ID)")
+	} else {
+		fmt.Printf("Decision: %s (%s confidence, from %s)\n", r.Decision.ToolID, r.Decision.Confidence, r.Decision.Signal)
+	}
+	if len(r.AutoApplicable) == 0 {
+		fmt.Println("Auto-apply available for: (none)")
+	} else {
+		fmt.Printf("Auto-apply available for: %s\n", strings.Join(r.AutoApplicable, ", "))
+	}
+}
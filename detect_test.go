@@ -0,0 +1,270 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeFakeProc creates <root>/<pid>/{stat,cmdline} for each process.
+func writeFakeProc(t *testing.T, root string, procs []procInfo) {
+	t.Helper()
+	for _, p := range procs {
+		dir := filepath.Join(root, strconv.Itoa(p.PID))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		stat := strconv.Itoa(p.PID) + " (" + p.Comm + ") S " + strconv.Itoa(p.PPID) + " 0 0 0\n"
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmdline := strings.Join(p.Cmdline, "\x00") + "\x00"
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectTool(t *testing.T) {
+	t.Parallel()
+
+	procRoot := t.TempDir()
+	writeFakeProc(t, procRoot, []procInfo{
+		{PID: 100, PPID: 90, Comm: "a21e", Cmdline: []string{"a21e", "init"}},
+		{PID: 90, PPID: 80, Comm: "zsh", Cmdline: []string{"-zsh"}},
+		{PID: 80, PPID: 1, Comm: "windsurf", Cmdline: []string{"/usr/share/windsurf/windsurf", "--type=ptyHost"}},
+	})
+	agentRoot := t.TempDir()
+	writeFakeProc(t, agentRoot, []procInfo{
+		{PID: 100, PPID: 95, Comm: "a21e", Cmdline: []string{"a21e", "init"}},
+		{PID: 95, PPID: 90, Comm: "bash", Cmdline: []string{"/bin/bash", "-c", "a21e init"}},
+		{PID: 90, PPID: 80, Comm: "node", Cmdline: []string{"node", "/usr/lib/node_modules/@openai/codex/bin/codex.js"}},
+		{PID: 80, PPID: 1, Comm: "code", Cmdline: []string{"/usr/share/code/code"}},
+	})
+	aiderRoot := t.TempDir()
+	writeFakeProc(t, aiderRoot, []procInfo{
+		{PID: 100, PPID: 90, Comm: "a21e", Cmdline: []string{"a21e", "init"}},
+		{PID: 90, PPID: 1, Comm: "python3", Cmdline: []string{"/usr/bin/python3.12", "/home/dev/.local/bin/aider", "--model", "a21e-auto"}},
+	})
+
+	testCases := []struct {
+		name       string
+		env        map[string]string
+		procRoot   string
+		wantTool   string
+		confidence string
+		signal     string
+	}{
+		{
+			name:       "explicit override wins",
+			env:        map[string]string{"A21E_TOOL_ID": "Codex_CLI", "CURSOR_TRACE_ID": "abc"},
+			wantTool:   "codex_cli",
+			confidence: confidenceExplicit,
+			signal:     "A21E_TOOL_ID",
+		},
+		{
+			name:       "cursor trace id beats TERM_PROGRAM=vscode",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "CURSOR_TRACE_ID": "abc"},
+			wantTool:   "cursor",
+			confidence: confidenceHigh,
+			signal:     "CURSOR_TRACE_ID",
+		},
+		{
+			name:       "claude code env marker beats editor",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "CURSOR_TRACE_ID": "abc", "CLAUDECODE": "1"},
+			wantTool:   "claude_code_cli",
+			confidence: confidenceHigh,
+			signal:     "CLAUDECODE",
+		},
+		{
+			name:       "codex sandbox marker",
+			env:        map[string]string{"CODEX_SANDBOX": "seatbelt"},
+			wantTool:   "codex_cli",
+			confidence: confidenceHigh,
+			signal:     "CODEX_SANDBOX",
+		},
+		{
+			name:       "codex node script in process tree beats parent editor",
+			env:        map[string]string{"TERM_PROGRAM": "vscode"},
+			procRoot:   agentRoot,
+			wantTool:   "codex_cli",
+			confidence: confidenceMedium,
+			signal:     "parent process 90",
+		},
+		{
+			name:       "aider python script in process tree",
+			env:        map[string]string{},
+			procRoot:   aiderRoot,
+			wantTool:   "openai_cli_custom",
+			confidence: confidenceMedium,
+			signal:     "Aider",
+		},
+		{
+			name:       "askpass node path names Cursor",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "VSCODE_GIT_ASKPASS_NODE": "/Applications/Cursor.app/Contents/Frameworks/Cursor Helper (Plugin).app/Contents/MacOS/Cursor Helper (Plugin)"},
+			wantTool:   "cursor",
+			confidence: confidenceHigh,
+			signal:     "VSCODE_GIT_ASKPASS_NODE",
+		},
+		{
+			name:       "askpass node path names Insiders",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "VSCODE_GIT_ASKPASS_NODE": "/home/dev/.vscode-server-insiders/bin/abc/node"},
+			wantTool:   "vscode_insiders",
+			confidence: confidenceHigh,
+			signal:     "VSCODE_GIT_ASKPASS_NODE",
+		},
+		{
+			name:       "askpass node path names VS Code",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "VSCODE_GIT_ASKPASS_NODE": "/usr/share/code/code"},
+			wantTool:   "vscode",
+			confidence: confidenceHigh,
+			signal:     "VSCODE_GIT_ASKPASS_NODE",
+		},
+		{
+			name:       "vscode ipc hook does not hide Windsurf parent",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "VSCODE_IPC_HOOK_CLI": "/run/user/1000/vscode-ipc-3f2a9c1e.sock"},
+			procRoot:   procRoot,
+			wantTool:   "windsurf",
+			confidence: confidenceMedium,
+			signal:     "parent process 80",
+		},
+		{
+			name:       "vscode ipc hook alone is not a VS Code signal",
+			env:        map[string]string{"TERM_PROGRAM": "vscode", "VSCODE_IPC_HOOK_CLI": "/run/user/1000/vscode-ipc-3f2a9c1e.sock"},
+			wantTool:   "vscode",
+			confidence: confidenceLow,
+			signal:     "TERM_PROGRAM=vscode",
+		},
+		{
+			name:       "parent process names Windsurf",
+			env:        map[string]string{"TERM_PROGRAM": "vscode"},
+			procRoot:   procRoot,
+			wantTool:   "windsurf",
+			confidence: confidenceMedium,
+			signal:     "parent process 80",
+		},
+		{
+			name:       "bare TERM_PROGRAM=vscode is low confidence",
+			env:        map[string]string{"TERM_PROGRAM": "vscode"},
+			wantTool:   "vscode",
+			confidence: confidenceLow,
+			signal:     "TERM_PROGRAM=vscode",
+		},
+		{
+			name:       "jetbrains terminal",
+			env:        map[string]string{"TERMINAL_EMULATOR": "JetBrains-JediTerm"},
+			wantTool:   "jetbrains",
+			confidence: confidenceMedium,
+			signal:     "TERMINAL_EMULATOR",
+		},
+		{
+			name:     "plain terminal",
+			env:      map[string]string{"TERM_PROGRAM": "iTerm.app"},
+			procRoot: procRoot,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			d := detectTool(detectEnv{getenv: mapEnv(tc.env), procRoot: tc.procRoot, pid: 100})
+			if tc.wantTool == "" {
+				if d != nil {
+					t.Fatalf("expected no detection, got %+v", d)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatalf("expected %s, got no detection", tc.wantTool)
+			}
+			if d.ToolID != tc.wantTool || d.Confidence != tc.confidence || !strings.Contains(d.Signal, tc.signal) {
+				t.Fatalf("detectTool = %+v, want tool %s confidence %s signal containing %q", d, tc.wantTool, tc.confidence, tc.signal)
+			}
+		})
+	}
+}
+
+func TestVSCodeFamilyFromPath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "/Applications/Cursor.app/Contents/Frameworks/Cursor Helper (Plugin).app/Contents/MacOS/Cursor Helper (Plugin)", want: "cursor"},
+		{path: "/Applications/Visual Studio Code - Insiders.app/Contents/Frameworks/Code - Insiders Helper (Plugin).app/Contents/MacOS/Code - Insiders Helper (Plugin)", want: "vscode_insiders"},
+		{path: "/home/dev/.windsurf-server/bin/abc/node", want: "windsurf"},
+		{path: "/usr/share/code-insiders/code-insiders", want: "vscode_insiders"},
+		{path: `C:\Users\dev\AppData\Local\Programs\cursor\Cursor.exe`, want: "cursor"},
+		{path: "/usr/share/code/code", want: "vscode"},
+		{path: "/home/cursor-dev/.vscode-server/bin/abc/node", want: "vscode"},
+		{path: "/home/dev/windsurf-notes/insiders/vscode-ipc-3f2a9c1e.sock", want: ""},
+		{path: "/run/user/1000/vscode-ipc-3f2a9c1e.sock", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := vscodeFamilyFromPath(tc.path); got != tc.want {
+			t.Errorf("vscodeFamilyFromPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestProcAncestors(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFakeProc(t, root, []procInfo{
+		{PID: 30, PPID: 20, Comm: "a21e"},
+		{PID: 20, PPID: 10, Comm: "tmux: server (1)"},
+		{PID: 10, PPID: 1, Comm: "sshd"},
+	})
+
+	chain := procAncestors(root, 30)
+	if len(chain) != 2 {
+		t.Fatalf("procAncestors returned %d entries, want 2: %+v", len(chain), chain)
+	}
+	if chain[0].Comm != "tmux: server (1)" || chain[1].PID != 10 {
+		t.Fatalf("unexpected chain: %+v", chain)
+	}
+}
+
+func TestBuildDetectReport(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	env := detectEnv{getenv: mapEnv(map[string]string{
+		"A21E_TOOL_ID": "emacs",
+		"TERM_PROGRAM": "vscode",
+	})}
+	lookPath := func(name string) (string, error) {
+		if name == "code" {
+			return "/usr/bin/code", nil
+		}
+		return "", os.ErrNotExist
+	}
+
+	report := buildDetectReport(env, lookPath)
+
+	if report.Signals[0].Name != "A21E_TOOL_ID" || !strings.Contains(report.Signals[0].Note, "ignored") {
+		t.Fatalf("expected invalid A21E_TOOL_ID to be reported as ignored, got %+v", report.Signals[0])
+	}
+	if report.Decision == nil || report.Decision.ToolID != "vscode" || report.Decision.Confidence != confidenceLow {
+		t.Fatalf("unexpected decision: %+v", report.Decision)
+	}
+	if len(report.Installed) != 1 || report.Installed[0].Evidence != "/usr/bin/code" {
+		t.Fatalf("unexpected installed tools: %+v", report.Installed)
+	}
+	found := false
+	for _, id := range report.AutoApplicable {
+		if id == "vscode" {
+			found = true
+		}
+		if id == "cursor" {
+			t.Fatalf("cursor is not installed but was reported as auto-applicable")
+		}
+	}
+	if !found {
+		t.Fatalf("expected vscode to be auto-applicable, got %v", report.AutoApplicable)
+	}
+}
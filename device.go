@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,9 +17,6 @@ import (
 	"time"
 )
 
-const devicePollInterval = 2 * time.Second
-const devicePollTimeout = 5 * time.Minute
-
 type deviceStartResp struct {
 	DeviceCode     string `json:"device_code"`
 	UserCode       string `json:"user_code"`
@@ -34,14 +32,14 @@ type devicePollResp struct {
 // startDeviceFlow runs the device authorization flow: POST device, show URL, poll until authorized.
 // Returns the API key or an error.
 func startDeviceFlow(baseURL string) (string, error) {
-	url := strings.TrimSuffix(baseURL, "/") + "/v1/cli/device"
-	req, err := http.NewRequest("POST", url, bytes.NewReader([]byte("{}")))
+	deviceURL := endpointsFor(baseURL).DeviceAuth
+	req, err := http.NewRequest("POST", deviceURL, bytes.NewReader([]byte("{}")))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
 	}
@@ -51,9 +49,7 @@ func startDeviceFlow(baseURL string) (string, error) {
 		return "", err
 	}
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		var ae apiError
-		_ = json.Unmarshal(raw, &ae)
-		return "", fmt.Errorf("API %d: %s", resp.StatusCode, ae.Error)
+		return "", newAPIStatusError(resp.StatusCode, raw)
 	}
 
 	var start deviceStartResp
@@ -62,20 +58,24 @@ func startDeviceFlow(baseURL string) (string, error) {
 	}
 
 	fmt.Fprintf(os.Stderr, "\nOpen this URL to sign in and authorize this device:\n\n  %s\n\n", start.VerificationURI)
-	openBrowser(start.VerificationURI)
+	_ = openBrowser(start.VerificationURI)
 
 	// Poll until authorized or timeout
-	deadline := time.Now().Add(devicePollTimeout)
-	pollURL := strings.TrimSuffix(baseURL, "/") + "/v1/cli/device?device_code=" + start.DeviceCode
+	interval := durationSetting("device_poll_interval")
+	deadline := time.Now().Add(durationSetting("device_timeout"))
+	pollURL := deviceURL + "?device_code=" + url.QueryEscape(start.DeviceCode)
+	if strings.Contains(deviceURL, "?") {
+		pollURL = deviceURL + "&device_code=" + url.QueryEscape(start.DeviceCode)
+	}
 
 	for time.Now().Before(deadline) {
-		time.Sleep(devicePollInterval)
+		time.Sleep(interval)
 
 		req, err := http.NewRequest("GET", pollURL, nil)
 		if err != nil {
 			continue
 		}
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := httpClient().Do(req)
 		if err != nil {
 			continue
 		}
@@ -102,14 +102,21 @@ func startDeviceFlow(baseURL string) (string, error) {
 	return "", fmt.Errorf("timed out waiting for authorization")
 }
 
-func openBrowser(url string) {
+// openBrowser asks the OS to open url. It returns an error when there is no
+// usable browser (no opener binary, or no graphical session on Linux).
+func openBrowser(url string) error {
 	switch runtime.GOOS {
 	case "darwin":
-		_ = exec.Command("open", url).Start()
+		return exec.Command("open", url).Start()
 	case "linux":
-		_ = exec.Command("xdg-open", url).Start()
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return fmt.Errorf("no graphical session (DISPLAY and WAYLAND_DISPLAY are unset)")
+		}
+		return exec.Command("xdg-open", url).Start()
 	case "windows":
-		_ = exec.Command("cmd", "/c", "start", url).Start()
+		return exec.Command("cmd", "/c", "start", url).Start()
+	default:
+		return fmt.Errorf("opening a browser is not supported on %s", runtime.GOOS)
 	}
 }
 
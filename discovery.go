@@ -0,0 +1,189 @@
+// discovery.go — Endpoint discovery from <api_url>/.well-known/a21e.json.
+//
+// Self-hosted deployments can serve the REST API, the OpenAI-compatible API, device
+// login and the dashboard under any paths. They publish them in a metadata document:
+//
+//	{
+//	  "api_url": "https://a21e.corp.example/api",
+//	  "openai_base_url": "https://a21e.corp.example/llm/v1",
+//	  "device_authorization_url": "https://a21e.corp.example/auth/device",
+//	  "dashboard_url": "https://a21e.corp.example/app"
+//	}
+//
+// URLs may be relative to the document. The document is not authenticated and it
+// decides where the API key is sent, so a URL is only used if it stays on the
+// api_url host (or a host listed in discovery_hosts) and does not downgrade https
+// to http. Missing or rejected fields, and a missing or unreadable document, fall
+// back to the hosted conventions (<api_url>/v1, <api_url>/v1/cli/device). The
+// hosted API itself follows those conventions and is never asked.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const wellKnownPath = "/.well-known/a21e.json"
+
+const (
+	defaultAPIURL       = "https://api.a21e.com"
+	defaultDashboardURL = "https://a21e.com"
+)
+
+// discoveryTimeout bounds the metadata request so an unreachable server fails on
+// the real request, with its real error, rather than here.
+const discoveryTimeout = 5 * time.Second
+
+// endpoints are the URLs the CLI talks to for one api_url.
+type endpoints struct {
+	API        string `json:"api_url"`                  // REST API root; request paths start with /v1
+	OpenAI     string `json:"openai_base_url"`          // OpenAI-compatible base, ending in its version path
+	DeviceAuth string `json:"device_authorization_url"` // device login: POST to start, GET ?device_code= to poll
+	Dashboard  string `json:"dashboard_url"`
+	Discovered bool   `json:"-"` // false when every URL is a fallback
+}
+
+var (
+	discoveryMu    sync.Mutex
+	discoveryCache = map[string]endpoints{}
+)
+
+// endpointsFor returns the endpoints for apiBaseURL, fetching the discovery
+// document once per process.
+func endpointsFor(apiBaseURL string) endpoints {
+	base := strings.TrimSpace(strings.TrimRight(apiBaseURL, "/"))
+	if base == "" {
+		return conventionalEndpoints(defaultAPIURL)
+	}
+	if u, err := url.Parse(base); err == nil && "https://"+u.Host == defaultAPIURL {
+		return conventionalEndpoints(base)
+	}
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	if ep, ok := discoveryCache[base]; ok {
+		return ep
+	}
+	ep := conventionalEndpoints(base)
+	if doc, ok := fetchDiscovery(base); ok {
+		ep = doc.withFallback(ep)
+	}
+	discoveryCache[base] = ep
+	return ep
+}
+
+// conventionalEndpoints are the hosted service's paths, used without discovery.
+func conventionalEndpoints(base string) endpoints {
+	openAI := base + "/v1"
+	if strings.HasSuffix(base, "/v1") {
+		openAI = base
+	}
+	return endpoints{
+		API:        base,
+		OpenAI:     openAI,
+		DeviceAuth: base + "/v1/cli/device",
+		Dashboard:  defaultDashboardURL,
+	}
+}
+
+func (e endpoints) withFallback(fallback endpoints) endpoints {
+	pick := func(v, def string) string {
+		if v == "" {
+			return def
+		}
+		return strings.TrimRight(v, "/")
+	}
+	return endpoints{
+		API:        pick(e.API, fallback.API),
+		OpenAI:     pick(e.OpenAI, fallback.OpenAI),
+		DeviceAuth: pick(e.DeviceAuth, fallback.DeviceAuth),
+		Dashboard:  pick(e.Dashboard, fallback.Dashboard),
+		Discovered: true,
+	}
+}
+
+// fetchDiscovery reads the metadata document. Any failure means "no discovery".
+func fetchDiscovery(base string) (endpoints, bool) {
+	docURL, err := url.Parse(base + wellKnownPath)
+	if err != nil {
+		return endpoints{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docURL.String(), nil)
+	if err != nil {
+		return endpoints{}, false
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return endpoints{}, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return endpoints{}, false
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return endpoints{}, false
+	}
+	var doc endpoints
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return endpoints{}, false
+	}
+	extraHosts := discoveryHosts()
+	for _, field := range []*string{&doc.API, &doc.OpenAI, &doc.DeviceAuth, &doc.Dashboard} {
+		*field = resolveDiscoveredURL(docURL, *field, extraHosts)
+	}
+	if doc == (endpoints{}) {
+		return endpoints{}, false
+	}
+	return doc, true
+}
+
+// resolveDiscoveredURL resolves ref against the document URL. It drops anything
+// that is not an http(s) URL, that is http when the document was fetched over
+// https, or that points at a host other than the document's and extraHosts.
+func resolveDiscoveredURL(docURL *url.URL, ref string, extraHosts []string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := docURL.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	if docURL.Scheme == "https" && u.Scheme != "https" {
+		return ""
+	}
+	if u.Host != docURL.Host && !containsFold(extraHosts, u.Host) && !containsFold(extraHosts, u.Hostname()) {
+		return ""
+	}
+	return u.String()
+}
+
+// discoveryHosts lists the discovery_hosts setting: hosts besides api_url's that
+// discovered URLs may use.
+func discoveryHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(settingValue("discovery_hosts"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
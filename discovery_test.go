@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEndpointsFromDiscovery(t *testing.T) {
+	t.Parallel()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case wellKnownPath:
+			w.Write([]byte(`{
+				"api_url": "/api",
+				"openai_base_url": "` + srv.URL + `/llm/v1/",
+				"device_authorization_url": "auth/device",
+				"dashboard_url": "javascript:alert(1)"
+			}`))
+		case "/api/v1/workspaces/default":
+			w.Write([]byte(`{"id":"ws_1","name":"Default"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ep := endpointsFor(srv.URL + "/")
+	want := endpoints{
+		API:        srv.URL + "/api",
+		OpenAI:     srv.URL + "/llm/v1",
+		DeviceAuth: srv.URL + "/.well-known/auth/device",
+		Dashboard:  defaultDashboardURL,
+		Discovered: true,
+	}
+	if ep != want {
+		t.Fatalf("endpoints = %+v\nwant %+v", ep, want)
+	}
+	if got := openAIBaseURL(srv.URL); got != want.OpenAI {
+		t.Fatalf("openAIBaseURL = %q, want %q", got, want.OpenAI)
+	}
+	settings, _, err := mergeA21ESettings(nil, "k", srv.URL, defaultModel)
+	if err != nil || !strings.Contains(string(settings), `"a21e.apiUrl": "`+want.API+`"`) {
+		t.Fatalf("editor settings = %s, %v; want a21e.apiUrl %s", settings, err, want.API)
+	}
+	ws, err := getDefaultWorkspace("k", srv.URL)
+	if err != nil {
+		t.Fatalf("request under discovered api_url: %v", err)
+	}
+	if ws.ID != "ws_1" {
+		t.Fatalf("workspace = %+v", ws)
+	}
+}
+
+func TestEndpointsFallBackToConventions(t *testing.T) {
+	t.Parallel()
+	cases := map[string]http.HandlerFunc{
+		"missing":   http.NotFound,
+		"not json":  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html>")) },
+		"no fields": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{"other":"x"}`)) },
+	}
+	for name, h := range cases {
+		h := h
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			ep := endpointsFor(srv.URL)
+			want := endpoints{API: srv.URL, OpenAI: srv.URL + "/v1", DeviceAuth: srv.URL + "/v1/cli/device", Dashboard: defaultDashboardURL}
+			if ep != want {
+				t.Fatalf("endpoints = %+v\nwant %+v", ep, want)
+			}
+		})
+	}
+}
+
+func TestResolveDiscoveredURL(t *testing.T) {
+	t.Parallel()
+	https, _ := url.Parse("https://a21e.corp.example/.well-known/a21e.json")
+	plain, _ := url.Parse("http://127.0.0.1:8080/.well-known/a21e.json")
+	cases := []struct {
+		doc   *url.URL
+		ref   string
+		extra []string
+		want  string
+	}{
+		{doc: https, ref: "/api", want: "https://a21e.corp.example/api"},
+		{doc: https, ref: "https://a21e.corp.example/llm/v1", want: "https://a21e.corp.example/llm/v1"},
+		{doc: https, ref: "http://a21e.corp.example/api", want: ""},
+		{doc: https, ref: "https://evil.example/v1", want: ""},
+		{doc: https, ref: "https://llm.corp.example/v1", extra: []string{"LLM.corp.example"}, want: "https://llm.corp.example/v1"},
+		{doc: https, ref: "https://a21e.corp.example:8443/api", want: ""},
+		{doc: https, ref: "javascript:alert(1)", want: ""},
+		{doc: plain, ref: "https://127.0.0.1:8080/api", want: "https://127.0.0.1:8080/api"},
+		{doc: plain, ref: "http://127.0.0.1:9090/api", want: ""},
+	}
+	for _, c := range cases {
+		if got := resolveDiscoveredURL(c.doc, c.ref, c.extra); got != c.want {
+			t.Errorf("resolveDiscoveredURL(%s, %q, %q) = %q, want %q", c.doc, c.ref, c.extra, got, c.want)
+		}
+	}
+}
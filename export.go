@@ -0,0 +1,196 @@
+// export.go — Write the init result (base URL, key, model) for CI and scripts.
+//
+// Formats:
+//   - dotenv:         managed block in a .env file (default ./.env), mode 0600
+//   - github-actions: append to $GITHUB_ENV and print ::add-mask:: for the key
+//   - gitlab:         dotenv report file for artifacts:reports:dotenv (default ./a21e.env)
+//   - shell:          export statements to stdout (or a file) for eval "$(...)"
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var exportFormats = []string{"dotenv", "github-actions", "gitlab", "shell"}
+
+const exportBlockStart = "# >>> a21e export >>>"
+const exportBlockEnd = "# <<< a21e export <<<"
+
+type exportVar struct {
+	Name   string
+	Value  string
+	Secret bool
+}
+
+// exportResult is reported in the init --output json document.
+type exportResult struct {
+	Format string `json:"format"`
+	Path   string `json:"path"`
+}
+
+func isValidExportFormat(format string) bool {
+	for _, f := range exportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveExportTarget validates the export options and returns the path to write,
+// or "-" for stdout. It runs before any key is created so bad flags fail fast.
+func resolveExportTarget(format, path string) (string, error) {
+	if format == "" {
+		if path != "" {
+			return "", errors.New("--export-file requires --export-format")
+		}
+		return "", nil
+	}
+	if !isValidExportFormat(format) {
+		return "", fmt.Errorf("invalid --export-format %q. Supported: %s", format, strings.Join(exportFormats, ", "))
+	}
+	if path != "" {
+		return path, nil
+	}
+	switch format {
+	case "dotenv":
+		return ".env", nil
+	case "gitlab":
+		return "a21e.env", nil
+	case "github-actions":
+		if p := os.Getenv("GITHUB_ENV"); p != "" {
+			return p, nil
+		}
+		return "", errors.New("--export-format github-actions needs $GITHUB_ENV or --export-file")
+	default:
+		return "-", nil
+	}
+}
+
+// exportVarsFor uses the same variable names as the managed shell profile block.
+func exportVarsFor(baseURL, key, model string) []exportVar {
+	return []exportVar{
+		{Name: "OPENAI_API_BASE", Value: baseURL},
+		{Name: "OPENAI_BASE_URL", Value: baseURL},
+		{Name: "OPENAI_API_KEY", Value: key, Secret: true},
+		{Name: "A21E_MODEL", Value: model},
+	}
+}
+
+// writeExport writes vars in format to path ("-" is stdout) and reports whether
+// anything was written. Masking commands for GitHub Actions go to stdout, where the
+// runner reads workflow commands (callers pass stderr in JSON mode so the result
+// document stays parseable; the runner reads commands from both streams).
+func writeExport(format, path string, vars []exportVar, stdout io.Writer) (bool, error) {
+	for _, v := range vars {
+		if strings.ContainsAny(v.Value, "\r\n") {
+			return false, fmt.Errorf("value for %s contains a newline", v.Name)
+		}
+	}
+
+	switch format {
+	case "github-actions":
+		for _, v := range vars {
+			if v.Secret {
+				fmt.Fprintf(stdout, "::add-mask::%s\n", v.Value)
+			}
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return false, fmt.Errorf("could not open %s: %w", path, err)
+		}
+		defer f.Close()
+		if _, err := io.WriteString(f, renderDotenv(vars)); err != nil {
+			return false, fmt.Errorf("could not write %s: %w", path, err)
+		}
+		return true, nil
+	case "shell":
+		if path == "-" {
+			_, err := io.WriteString(stdout, renderShellExports(vars))
+			return err == nil, err
+		}
+		return writeManagedExportFile(path, renderShellExports(vars))
+	default:
+		return writeManagedExportFile(path, renderDotenv(vars))
+	}
+}
+
+func writeManagedExportFile(path, body string) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	block := exportBlockStart + "\n" + body + exportBlockEnd
+	updated, changed, err := upsertManagedBlock(string(existing), exportBlockStart, exportBlockEnd, block)
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+	// Unlike tool configuration there is no backup: it would be another copy of the
+	// previous key. The file is written at 0600 whatever its old mode, so the key is
+	// never readable by others, even briefly.
+	target, err := resolveSymlinks(path)
+	if err != nil {
+		return false, fmt.Errorf("could not resolve %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return false, fmt.Errorf("could not create directory for %s: %w", path, err)
+	}
+	var like os.FileInfo
+	if info, err := os.Stat(target); err == nil {
+		like = info
+	}
+	if err := writeFileAtomic(target, []byte(updated), 0o600, like); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// readExportedValues returns OPENAI_API_KEY and A21E_MODEL from the managed block of an export file
+// written by writeExport, or "" when there is none. Appended formats (github-actions)
+// are not read back.
+func readExportedValues(format, path string) (key, model string, err error) {
+	if format == "github-actions" || path == "" || path == "-" {
+		return "", "", nil
+	}
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+	block, ok := findManagedBlock(string(existing), exportBlockStart, exportBlockEnd)
+	if !ok {
+		return "", "", nil
+	}
+	return managedBlockValue(block, "OPENAI_API_KEY"), managedBlockValue(block, "A21E_MODEL"), nil
+}
+
+func renderDotenv(vars []exportVar) string {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s=%s\n", v.Name, v.Value)
+	}
+	return b.String()
+}
+
+func renderShellExports(vars []exportVar) string {
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "export %s=%s\n", v.Name, shellQuote(v.Value))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
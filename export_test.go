@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteExport(t *testing.T) {
+	t.Parallel()
+
+	vars := exportVarsFor("https://api.a21e.com/v1", "a21e_secret", "a21e-auto")
+
+	testCases := []struct {
+		name      string
+		format    string
+		existing  string
+		wantFile  []string
+		wantOut   string
+		wantPerm  os.FileMode
+		checkPerm bool
+	}{
+		{
+			name:      "dotenv keeps other variables",
+			format:    "dotenv",
+			existing:  "DATABASE_URL=postgres://localhost\n",
+			wantFile:  []string{"DATABASE_URL=postgres://localhost", exportBlockStart, "OPENAI_API_KEY=a21e_secret", "A21E_MODEL=a21e-auto", exportBlockEnd},
+			wantPerm:  0o600,
+			checkPerm: true,
+		},
+		{
+			name:     "github actions appends and masks",
+			format:   "github-actions",
+			existing: "EXISTING=1\n",
+			wantFile: []string{"EXISTING=1\nOPENAI_API_BASE=https://api.a21e.com/v1\n", "OPENAI_API_KEY=a21e_secret"},
+			wantOut:  "::add-mask::a21e_secret\n",
+		},
+		{
+			name:      "gitlab dotenv report",
+			format:    "gitlab",
+			wantFile:  []string{"OPENAI_BASE_URL=https://api.a21e.com/v1"},
+			wantPerm:  0o600,
+			checkPerm: true,
+		},
+		{
+			name:     "shell exports are quoted",
+			format:   "shell",
+			wantFile: []string{"export OPENAI_API_KEY='a21e_secret'"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.env")
+			if tc.existing != "" {
+				if err := os.WriteFile(path, []byte(tc.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var stdout bytes.Buffer
+			if _, err := writeExport(tc.format, path, vars, &stdout); err != nil {
+				t.Fatalf("writeExport returned unexpected error: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tc.wantFile {
+				if !strings.Contains(string(got), want) {
+					t.Fatalf("exported file missing %q:\n%s", want, got)
+				}
+			}
+			if backups, _ := filepath.Glob(filepath.Join(dir, "*.bak-*")); len(backups) > 0 {
+				t.Fatalf("export left backups holding the old key: %v", backups)
+			}
+			if stdout.String() != tc.wantOut {
+				t.Fatalf("stdout = %q, want %q", stdout.String(), tc.wantOut)
+			}
+			if tc.checkPerm {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != tc.wantPerm {
+					t.Fatalf("mode = %v, want %v", info.Mode().Perm(), tc.wantPerm)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveExportTarget(t *testing.T) {
+	t.Parallel()
+
+	if _, err := resolveExportTarget("", "out.env"); err == nil {
+		t.Fatalf("expected --export-file without --export-format to fail")
+	}
+	if _, err := resolveExportTarget("yaml", ""); err == nil {
+		t.Fatalf("expected unknown format to fail")
+	}
+	if got, err := resolveExportTarget("shell", ""); err != nil || got != "-" {
+		t.Fatalf("shell default = %q, %v; want stdout", got, err)
+	}
+	if got, err := resolveExportTarget("dotenv", ""); err != nil || got != ".env" {
+		t.Fatalf("dotenv default = %q, %v; want .env", got, err)
+	}
+}
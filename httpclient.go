@@ -0,0 +1,312 @@
+// httpclient.go — The one HTTP client every API call goes through.
+//
+// It honors HTTPS_PROXY/HTTP_PROXY/NO_PROXY, trusts extra roots from ca_bundle
+// (A21E_CA_BUNDLE) on top of the system pool, presents client_cert/client_key for
+// mTLS, and rewrites certificate failures into errors that say what to configure.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpConfig is everything the shared client is built from. It is re-read on each
+// call so tests and `a21e config set` take effect; the client is rebuilt only when
+// it changes, keeping connections pooled between requests.
+type httpConfig struct {
+	httpsProxy string
+	httpProxy  string
+	noProxy    string
+	caBundle   string
+	clientCert string
+	clientKey  string
+	verbose    bool
+}
+
+var (
+	httpClientMu     sync.Mutex
+	httpClientConfig httpConfig
+	httpClientCached *http.Client
+)
+
+func currentHTTPConfig() httpConfig {
+	return httpConfig{
+		httpsProxy: firstEnv("HTTPS_PROXY", "https_proxy"),
+		httpProxy:  firstEnv("HTTP_PROXY", "http_proxy"),
+		noProxy:    firstEnv("NO_PROXY", "no_proxy"),
+		caBundle:   expandHome(settingValue("ca_bundle")),
+		clientCert: expandHome(settingValue("client_cert")),
+		clientKey:  expandHome(settingValue("client_key")),
+		verbose:    verbose,
+	}
+}
+
+func firstEnv(names ...string) string {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// httpClient returns the shared client. A bad CA bundle or client certificate is
+// not reported here but by every request made with the client, as a validation
+// error, so call sites stay a plain httpClient().Do(req).
+func httpClient() *http.Client {
+	cfg := currentHTTPConfig()
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if httpClientCached != nil && httpClientConfig == cfg {
+		return httpClientCached
+	}
+	var rt http.RoundTripper
+	base, err := newHTTPTransport(cfg)
+	if err != nil {
+		rt = failingTransport{err: withExitCode(exitValidation, err)}
+	} else {
+		rt = &explainingTransport{base: base, cfg: cfg}
+	}
+	if cfg.verbose {
+		rt = &verboseTransport{base: rt, cfg: cfg}
+	}
+	if httpClientCached != nil {
+		rt := httpClientCached.Transport
+		if v, ok := rt.(*verboseTransport); ok {
+			rt = v.base
+		}
+		if t, ok := rt.(*explainingTransport); ok {
+			t.base.CloseIdleConnections()
+		}
+	}
+	httpClientConfig = cfg
+	httpClientCached = &http.Client{Transport: rt}
+	return httpClientCached
+}
+
+func newHTTPTransport(cfg httpConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = cfg.proxyFor
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+func (c httpConfig) tlsConfig() (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(c.caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle (ca_bundle): %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", c.caBundle)
+		}
+		tc.RootCAs = pool
+	}
+	switch {
+	case c.clientCert != "" && c.clientKey != "":
+		cert, err := tls.LoadX509KeyPair(c.clientCert, c.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate %s with key %s: %w", c.clientCert, c.clientKey, err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	case c.clientCert != "":
+		return nil, errors.New("client_cert is set but client_key is not (set A21E_CLIENT_KEY or `a21e config set client_key`)")
+	case c.clientKey != "":
+		return nil, errors.New("client_key is set but client_cert is not (set A21E_CLIENT_CERT or `a21e config set client_cert`)")
+	}
+	return tc, nil
+}
+
+// proxyFor picks the proxy for req the way curl and Go's ProxyFromEnvironment do:
+// HTTPS_PROXY for https, HTTP_PROXY for http, nothing for loopback hosts or hosts
+// matched by NO_PROXY. Unlike ProxyFromEnvironment it does not cache the
+// environment for the life of the process.
+func (c httpConfig) proxyFor(req *http.Request) (*url.URL, error) {
+	raw := c.httpProxy
+	if req.URL.Scheme == "https" {
+		raw = c.httpsProxy
+	}
+	if raw == "" || !c.useProxy(req.URL) {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+		// Like curl, a bare host:port means an http proxy.
+		if u2, err2 := url.Parse("http://" + raw); err2 == nil && u2.Host != "" {
+			return u2, nil
+		}
+		return nil, fmt.Errorf("invalid proxy address %q", raw)
+	}
+	return u, nil
+}
+
+// useProxy reports whether u should go through a proxy given NO_PROXY. Entries are
+// comma-separated: "*", an IP, a CIDR, or a domain (with or without a leading dot,
+// matching the domain and its subdomains), each optionally with :port.
+func (c httpConfig) useProxy(u *url.URL) bool {
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+	for _, entry := range strings.Split(c.noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return false
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return false
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(entryHost, ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// failingTransport reports a client configuration error for every request.
+type failingTransport struct{ err error }
+
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// explainingTransport turns certificate failures into errors that say what to do.
+type explainingTransport struct {
+	base *http.Transport
+	cfg  httpConfig
+}
+
+func (t *explainingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, explainTLSError(req.URL.Host, t.cfg, err)
+	}
+	return resp, nil
+}
+
+// verboseTransport logs each request, the proxy it goes through and the outcome to
+// stderr (--verbose). Query strings and credentials in URLs are left out.
+type verboseTransport struct {
+	base http.RoundTripper
+	cfg  httpConfig
+}
+
+func (t *verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	u.User, u.RawQuery = nil, ""
+	via := ""
+	if p, err := t.cfg.proxyFor(req); err == nil && p != nil {
+		via = " via proxy " + p.Redacted()
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "a21e: %s %s%s failed after %s: %v\n", req.Method, u.String(), via, elapsed, err)
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "a21e: %s %s%s -> %s (%s)\n", req.Method, u.String(), via, resp.Status, elapsed)
+	return resp, nil
+}
+
+// tlsError is a certificate failure with advice attached. It unwraps to the
+// original error so exit codes and errors.As keep working.
+type tlsError struct {
+	host   string
+	reason string
+	advice string
+	err    error
+}
+
+func (e *tlsError) Error() string {
+	return fmt.Sprintf("TLS connection to %s failed: %s (%v). %s", e.host, e.reason, e.err, e.advice)
+}
+
+func (e *tlsError) Unwrap() error { return e.err }
+
+// explainTLSError returns err unchanged unless it is a certificate problem.
+func explainTLSError(host string, cfg httpConfig, err error) error {
+	trustAdvice := "If your network inspects TLS, set A21E_CA_BUNDLE (or `a21e config set ca_bundle <file>`) to a PEM file with your organization's root certificate"
+	if cfg.caBundle != "" {
+		trustAdvice = fmt.Sprintf("The CA bundle %s does not contain the certificate that signed it; ask your network team for the right root certificate", cfg.caBundle)
+	}
+
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknown):
+		return &tlsError{host: host, reason: "the server certificate is signed by an unknown authority", advice: trustAdvice, err: err}
+	case errors.As(err, &hostname):
+		return &tlsError{host: host, reason: "the server certificate is not valid for this host name", advice: "Check api_url, and any proxy or DNS settings that may send the request somewhere else", err: err}
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return &tlsError{host: host, reason: "the server certificate has expired or is not yet valid", advice: "Check that this machine's clock is correct", err: err}
+	case errors.As(err, &invalid):
+		return &tlsError{host: host, reason: "the server certificate was rejected", advice: trustAdvice, err: err}
+	}
+
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return &tlsError{host: host, reason: "the server certificate could not be verified", advice: trustAdvice, err: err}
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "remote error: tls: certificate required") || strings.Contains(msg, "remote error: tls: bad certificate") ||
+		strings.Contains(msg, "remote error: tls: unknown certificate authority") {
+		advice := "The server requires a client certificate; set A21E_CLIENT_CERT and A21E_CLIENT_KEY (or `a21e config set client_cert|client_key`)"
+		if cfg.clientCert != "" {
+			advice = fmt.Sprintf("The server did not accept the client certificate %s; check that it is the one issued for this API", cfg.clientCert)
+		}
+		return &tlsError{host: host, reason: "the server rejected this client", advice: advice, err: err}
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// isolateHTTPEnv clears proxy and TLS settings so the host environment cannot leak in.
+func isolateHTTPEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy",
+		"A21E_CA_BUNDLE", "A21E_CLIENT_CERT", "A21E_CLIENT_KEY", "A21E_PROFILE"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestUseProxy(t *testing.T) {
+	t.Parallel()
+	cfg := httpConfig{noProxy: "internal.example, .corp.example,10.0.0.0/8, 192.168.1.5, only.example:8443"}
+	cases := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.a21e.com", true},
+		{"https://internal.example", false},
+		{"https://svc.internal.example", false},
+		{"https://notinternal.example", true},
+		{"https://corp.example", false},
+		{"https://a.corp.example", false},
+		{"http://10.1.2.3", false},
+		{"http://11.1.2.3", true},
+		{"http://192.168.1.5:8080", false},
+		{"https://only.example:8443", false},
+		{"https://only.example", true},
+		{"http://localhost:8421", false},
+		{"http://127.0.0.1:9000", false},
+		{"http://[::1]:9000", false},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		if got := cfg.useProxy(u); got != c.want {
+			t.Errorf("useProxy(%s) = %v, want %v", c.url, got, c.want)
+		}
+	}
+	if (httpConfig{noProxy: "*"}).useProxy(&url.URL{Scheme: "https", Host: "api.a21e.com"}) {
+		t.Error(`NO_PROXY="*" still proxied`)
+	}
+}
+
+func TestHTTPClientUsesProxyFromEnvironment(t *testing.T) {
+	isolateHTTPEnv(t)
+
+	var mu sync.Mutex
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.Host)
+		mu.Unlock()
+		if r.Method == http.MethodConnect {
+			http.Error(w, "no tunnels here", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("HTTPS_PROXY", strings.TrimPrefix(proxy.URL, "http://")) // bare host:port
+	t.Setenv("NO_PROXY", "skip.invalid")
+
+	resp, err := httpClient().Get("http://api.a21e.invalid/v1/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := httpClient().Get("https://api.a21e.invalid/v1/ping"); err == nil {
+		t.Fatal("https request through a refusing proxy succeeded")
+	}
+	// NO_PROXY hosts are dialed directly, which fails for .invalid without reaching the proxy.
+	if _, err := httpClient().Get("http://api.skip.invalid/"); err == nil {
+		t.Fatal("request to a NO_PROXY host succeeded")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"GET api.a21e.invalid", "CONNECT api.a21e.invalid:443"}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Fatalf("proxy saw %q, want %q", seen, want)
+	}
+}
+
+func TestHTTPClientCABundle(t *testing.T) {
+	isolateHTTPEnv(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, err := httpClient().Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "unknown authority") || !strings.Contains(err.Error(), "A21E_CA_BUNDLE") {
+		t.Fatalf("untrusted server error = %v, want an explanation mentioning A21E_CA_BUNDLE", err)
+	}
+	if code := exitCodeFor(err); code != exitNetwork {
+		t.Fatalf("exit code = %d, want %d", code, exitNetwork)
+	}
+
+	bundle := filepath.Join(t.TempDir(), "corp-ca.pem")
+	writePEM(t, bundle, "CERTIFICATE", srv.Certificate().Raw)
+	t.Setenv("A21E_CA_BUNDLE", bundle)
+	resp, err := httpClient().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("with CA bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate\n"), 0o600)
+	t.Setenv("A21E_CA_BUNDLE", empty)
+	_, err = httpClient().Get(srv.URL)
+	if err == nil || exitCodeFor(err) != exitValidation || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Fatalf("bad bundle error = %v (exit %d), want a validation error", err, exitCodeFor(err))
+	}
+}
+
+func TestHTTPClientMutualTLS(t *testing.T) {
+	isolateHTTPEnv(t)
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	clientCert := writeClientCert(t, certPath, keyPath)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	bundle := filepath.Join(dir, "server-ca.pem")
+	writePEM(t, bundle, "CERTIFICATE", srv.Certificate().Raw)
+	t.Setenv("A21E_CA_BUNDLE", bundle)
+
+	_, err := httpClient().Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "A21E_CLIENT_CERT") {
+		t.Fatalf("no client certificate error = %v, want advice about A21E_CLIENT_CERT", err)
+	}
+
+	t.Setenv("A21E_CLIENT_CERT", certPath)
+	_, err = httpClient().Get(srv.URL)
+	if err == nil || exitCodeFor(err) != exitValidation {
+		t.Fatalf("cert without key error = %v, want a validation error", err)
+	}
+
+	t.Setenv("A21E_CLIENT_KEY", keyPath)
+	resp, err := httpClient().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("with client certificate: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key.
+func writeClientCert(t *testing.T, certPath, keyPath string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "a21e-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
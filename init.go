@@ -0,0 +1,439 @@
+// init.go — "a21e init": sign in if needed, create tool keys, apply and export configuration.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// initResult is the --output json document for a21e init (one per tool).
+type initResult struct {
+	KeyID     string        `json:"key_id,omitempty"`
+	Prefix    string        `json:"prefix,omitempty"`
+	Key       string        `json:"key,omitempty"` // only with --show-key
+	KeyMasked string        `json:"key_masked,omitempty"`
+	Tool      string        `json:"tool"`
+	Workspace string        `json:"workspace"`
+	BaseURL   string        `json:"base_url"`
+	Model     string        `json:"model"`
+	Apply     *applyResult  `json:"apply,omitempty"`
+	Export    *exportResult `json:"export,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// initBatchResult is the --output json document when init configures several tools.
+type initBatchResult struct {
+	Results []initResult `json:"results"`
+	Failed  int          `json:"failed"`
+}
+
+// applyResult reports the outcome of --apply: applied, unchanged, unsupported or failed.
+type applyResult struct {
+	Status      string `json:"status"`
+	UpdatedPath string `json:"updated_path,omitempty"`
+	BackupPath  string `json:"backup_path,omitempty"`
+	Details     string `json:"details,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func newApplyResult(summary *applySummary, err error) *applyResult {
+	switch {
+	case err == nil && summary.Unchanged:
+		return &applyResult{Status: "unchanged", UpdatedPath: summary.UpdatedPath, Details: summary.Details}
+	case err == nil:
+		return &applyResult{Status: "applied", UpdatedPath: summary.UpdatedPath, BackupPath: summary.BackupPath, Details: summary.Details}
+	case errors.Is(err, errAutoConfigUnsupported):
+		return &applyResult{Status: "unsupported", Error: err.Error()}
+	default:
+		return &applyResult{Status: "failed", Error: err.Error()}
+	}
+}
+
+// toolListFlag collects --tool values; it may be repeated and each value may be comma-separated.
+type toolListFlag []string
+
+func (f *toolListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *toolListFlag) Set(v string) error {
+	for _, id := range strings.Split(v, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		dup := false
+		for _, existing := range *f {
+			dup = dup || existing == id
+		}
+		if !dup {
+			*f = append(*f, id)
+		}
+	}
+	return nil
+}
+
+// initSettings is what every per-tool step of init shares.
+type initSettings struct {
+	apiKey       string
+	baseURL      string
+	workspace    string
+	scope        string
+	model        string
+	apply        bool
+	showKey      bool
+	exportFormat string
+	exportPath   string
+}
+
+// initFlags are the options of a21e init.
+type initFlags struct {
+	tools           *toolListFlag
+	allDetected     *bool
+	workspaceID     *string
+	workspaceScoped *bool
+	apply           *bool
+	nonInteractive  *bool
+	yes             *bool
+	deviceLogin     *bool
+	exportFormat    *string
+	showKey         *bool
+	exportFile      *string
+	model           *string
+}
+
+func newInitFlagSet() (*flag.FlagSet, initFlags) {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	tools := &toolListFlag{}
+	fs.Var(tools, "tool", "Tool ID to configure (e.g. claude_code_cli); repeat or comma-separate for several")
+	return fs, initFlags{
+		tools:           tools,
+		allDetected:     fs.Bool("all-detected", false, "Configure every supported tool installed on this machine"),
+		workspaceID:     fs.String("workspace", "", "Workspace ID (omit to use default)"),
+		workspaceScoped: fs.Bool("workspace-scoped", false, "Bind key to this workspace only"),
+		apply:           fs.Bool("apply", false, "Auto-apply configuration where supported"),
+		nonInteractive:  fs.Bool("non-interactive", false, "CI/non-interactive mode"),
+		yes:             fs.Bool("yes", false, "Skip confirmations"),
+		deviceLogin:     fs.Bool("device", false, "Use device code login instead of the local browser redirect"),
+		exportFormat:    fs.String("export-format", "", "Also write base URL, key and model as dotenv, github-actions, gitlab or shell"),
+		showKey:         fs.Bool("show-key", false, "Print the full API key (masked by default)"),
+		exportFile:      fs.String("export-file", "", "Destination for --export-format (default depends on format; - for stdout)"),
+		model:           fs.String("model", settingValue("model"), "Model to configure as the tool's default (see a21e models)"),
+	}
+}
+
+func runInit(args []string) {
+	fs, f := newInitFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	tools := *f.tools
+
+	exportPath, err := resolveExportTarget(*f.exportFormat, *f.exportFile)
+	if err != nil {
+		exitWithError("init", withExitCode(exitValidation, err))
+	}
+	if exportPath == "-" && jsonOutput() {
+		exitWithError("init", withExitCode(exitValidation, errors.New("--export-file must be a file path with --output json")))
+	}
+	for _, id := range tools {
+		if !isValidToolID(id) {
+			exitWithError("init", withExitCode(exitValidation, fmt.Errorf("invalid tool_id %q. Supported: %s", id, strings.Join(validToolIDs, ", "))))
+		}
+	}
+
+	out := humanOut()
+	baseURL := getAPIBaseURL()
+	apiKey, bootstrapKey := ensureAPIKey("init", baseURL, *f.nonInteractive, *f.deviceLogin, out)
+	if err := checkModels(openAIBaseURL(baseURL), apiKey, *f.model); err != nil {
+		exitWithError("init", err)
+	}
+
+	// --- Resolve workspace ---
+	var wid string
+	if *f.workspaceID != "" {
+		wid = *f.workspaceID
+	} else {
+		ws, err := getDefaultWorkspace(apiKey, baseURL)
+		if err != nil {
+			exitWithError("init", err)
+		}
+		wid = ws.ID
+		if *f.workspaceID == "" && !*f.nonInteractive && len(tools) == 0 {
+			fmt.Fprintf(out, "Using workspace: %s (%s)\n", ws.Name, wid)
+		}
+	}
+
+	// --- Tools: explicit flags, installed tools, auto-detect from environment, else prompt ---
+	if *f.allDetected {
+		for _, t := range toolRegistry {
+			if findInstalledTool(t, exec.LookPath) != nil {
+				_ = tools.Set(t.ID())
+			}
+		}
+		if len(tools) > 0 && !*f.nonInteractive {
+			fmt.Fprintf(out, "Installed tools: %s\n", strings.Join(tools, ", "))
+		}
+	}
+	if len(tools) == 0 {
+		if d := detectTool(systemDetectEnv()); d != nil {
+			tools = toolListFlag{d.ToolID}
+			if !*f.nonInteractive {
+				fmt.Fprintf(out, "Detected tool: %s (%s confidence, from %s)\n", d.ToolID, d.Confidence, d.Signal)
+				if d.Confidence == confidenceLow {
+					fmt.Fprintln(out, "If this is wrong, rerun with --tool <tool_id> or set A21E_TOOL_ID.")
+				}
+			}
+		}
+	}
+	if len(tools) == 0 {
+		if *f.nonInteractive || jsonOutput() {
+			exitWithError("init", withExitCode(exitValidation, errors.New("--tool is required in non-interactive mode (or set A21E_TOOL_ID)")))
+		}
+		fmt.Println("To create a CLI key for a tool, run:")
+		fmt.Printf("  a21e init --tool <tool_id> [--workspace %s]\n", wid)
+		fmt.Println("Supported tool_id:", strings.Join(validToolIDs, ", "))
+		fmt.Println("Or run 'a21e init' from inside Cursor, VS Code, or JetBrains terminal to auto-detect.")
+		fmt.Println("Or complete setup in the dashboard:", endpointsFor(baseURL).Dashboard)
+		return
+	}
+	if len(tools) > 1 && *f.exportFormat != "" {
+		exitWithError("init", withExitCode(exitValidation, errors.New("--export-format needs a single --tool (each tool gets its own key)")))
+	}
+
+	settings := initSettings{
+		apiKey:       apiKey,
+		baseURL:      baseURL,
+		workspace:    wid,
+		scope:        settingValue("scope"),
+		model:        *f.model,
+		apply:        *f.apply,
+		showKey:      *f.showKey,
+		exportFormat: *f.exportFormat,
+		exportPath:   exportPath,
+	}
+	if *f.workspaceScoped {
+		settings.scope = "workspace"
+	}
+	if !isValidScope(settings.scope) {
+		exitWithError("init", withExitCode(exitValidation, fmt.Errorf("invalid scope %q from config (want user or workspace)", settings.scope)))
+	}
+
+	// --- Create one key per tool; a failing tool does not stop the others ---
+	results := make([]initResult, 0, len(tools))
+	exitCode := exitOK
+	savedCredentials := false
+	for _, id := range tools {
+		if len(tools) > 1 {
+			fmt.Fprintf(out, "\n== %s ==\n", id)
+		}
+		result, key, err := setupTool(settings, id, !savedCredentials, out)
+		if key != "" {
+			savedCredentials = true
+		}
+		if err != nil {
+			if len(tools) == 1 && key == "" {
+				exitWithError("init", err)
+			}
+			fmt.Fprintf(out, "a21e init: %s: %v\n", id, err)
+			result.Error = err.Error()
+			if exitCode == exitOK {
+				exitCode = exitCodeFor(err)
+			}
+		}
+		results = append(results, result)
+	}
+
+	// The bootstrap key is in ~/.a21e/credentials until a tool key replaces it, so
+	// it is only revoked once one has.
+	switch {
+	case bootstrapKey != "" && savedCredentials:
+		if err := revokeBootstrapKeyIfPresent(bootstrapKey, baseURL, bootstrapKey); err != nil {
+			fmt.Fprintf(out, "a21e init: warning: could not revoke temporary bootstrap key: %v\n", err)
+		}
+	case bootstrapKey != "":
+		fmt.Fprintln(out, "No tool key was created; keeping the sign-in key in ~/.a21e/credentials so you can retry.")
+	}
+
+	if len(results) > 1 {
+		printInitSummary(out, results)
+	}
+
+	if jsonOutput() {
+		if len(results) == 1 {
+			writeJSON(results[0])
+		} else {
+			batch := initBatchResult{Results: results}
+			for _, r := range results {
+				if r.Error != "" {
+					batch.Failed++
+				}
+			}
+			writeJSON(batch)
+		}
+	} else if !*f.nonInteractive && !*f.yes && isTerminal() {
+		fmt.Fprint(os.Stderr, "Press Enter to continue... ")
+		bufio.NewReader(os.Stdin).ReadBytes('\n')
+	}
+	os.Exit(exitCode)
+}
+
+// ensureAPIKey returns the configured API key. Without one it signs in through the
+// browser (loopback redirect, else device flow), or exits in non-interactive mode.
+// bootstrapKey is the temporary key from that sign-in, to be revoked once the
+// command has created its own keys.
+func ensureAPIKey(command, baseURL string, nonInteractive, deviceLogin bool, out io.Writer) (apiKey, bootstrapKey string) {
+	if apiKey = getAPIKey(); apiKey != "" {
+		return apiKey, ""
+	}
+	if nonInteractive {
+		exitWithError(command, withExitCode(exitAuth, errors.New("A21E_API_KEY is required in non-interactive mode (or run without --non-interactive to use device login)")))
+	}
+	fmt.Fprintf(os.Stderr, "No API key found. Authorize this device in your browser to get a key.\n")
+	key, err := loginWithBrowser(baseURL, deviceLogin)
+	if err != nil {
+		if exitCodeFor(err) == exitError {
+			err = withExitCode(exitAuth, err)
+		}
+		exitWithError(command, err)
+	}
+	if err := writeCredentialsFile(key); err != nil {
+		fmt.Fprintf(out, "a21e %s: could not save key to file: %v\n", command, err)
+		fmt.Fprintf(out, "Save the key below and set A21E_API_KEY in your environment.\n")
+	}
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Device authorized. Bootstrapping tool setup with your new credentials…")
+	return key, key
+}
+
+// requireAPIKey returns the stored API key, or exits telling the user to sign in.
+// Commands that only use an existing key call this instead of ensureAPIKey.
+func requireAPIKey(command string) string {
+	apiKey := getAPIKey()
+	if apiKey == "" {
+		exitWithError(command, withExitCode(exitAuth, errors.New("no API key found; run a21e init first")))
+	}
+	return apiKey
+}
+
+// setupTool creates a key for toolID, saves it to the credentials file when
+// saveCredentials is set, then applies and exports it as requested. It returns the
+// new key ("" if none was created); err describes the first step that failed.
+func setupTool(s initSettings, toolID string, saveCredentials bool, out io.Writer) (initResult, string, error) {
+	result := initResult{
+		Tool:      toolID,
+		Workspace: s.workspace,
+		BaseURL:   openAIBaseURL(s.baseURL),
+		Model:     s.model,
+	}
+
+	resp, err := createCLIKey(s.apiKey, s.baseURL, s.workspace, toolID, suggestLabel(toolID), s.scope)
+	if err != nil {
+		return result, "", err
+	}
+	result.KeyID = resp.ID
+	result.Prefix = resp.Prefix
+	warnState(recordKey(resp, toolID, s.workspace, s.scope))
+	result.KeyMasked = maskKey(resp.Key)
+
+	showKey := s.showKey
+	if saveCredentials {
+		if err := writeCredentialsFile(resp.Key); err != nil {
+			fmt.Fprintf(out, "a21e init: could not save key to file: %v\n", err)
+			fmt.Fprintln(out, "You can still use this key by setting A21E_API_KEY manually.")
+			showKey = true // the key is not saved anywhere else, so do not mask it
+			fmt.Fprintln(out, "")
+		} else {
+			fmt.Fprintln(out, "")
+			fmt.Fprintln(out, "Tool key created and saved to ~/.a21e/credentials.")
+			fmt.Fprintln(out, "You do not need to manually export A21E_API_KEY for future a21e commands.")
+		}
+	}
+
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Tool configuration values:")
+	fmt.Fprintf(out, "  Base URL: %s\n", result.BaseURL)
+	fmt.Fprintf(out, "  API key:  %s\n", displayKey(resp.Key, showKey))
+	if !showKey {
+		fmt.Fprintln(out, "            (masked; use --show-key to print it)")
+	}
+	fmt.Fprintf(out, "  Model:    %s\n", result.Model)
+	fmt.Fprintln(out, "")
+	if showKey {
+		result.Key = resp.Key
+	}
+
+	var stepErr error
+	if s.apply {
+		summary, err := applyToolConfiguration(toolID, resp.Key, s.baseURL, s.model)
+		result.Apply = newApplyResult(summary, err)
+		if err == nil {
+			warnState(recordApply(resp.ID, toolID, summary))
+			fmt.Fprintln(out, "Auto-configuration applied:")
+			fmt.Fprintf(out, "  %s\n", summary.Details)
+			fmt.Fprintf(out, "  Updated: %s\n", summary.UpdatedPath)
+			if summary.BackupPath != "" {
+				fmt.Fprintf(out, "  Backup:  %s\n", summary.BackupPath)
+			}
+			fmt.Fprintln(out, "")
+		} else if errors.Is(err, errAutoConfigUnsupported) {
+			fmt.Fprintln(out, "Auto-configuration is not supported for this tool yet.")
+			fmt.Fprintln(out, "Configure your tool manually with the values above.")
+			fmt.Fprintln(out, "")
+		} else {
+			fmt.Fprintf(out, "Auto-configuration failed: %v\n", err)
+			fmt.Fprintln(out, "Configure your tool manually with the values above.")
+			fmt.Fprintln(out, "")
+			stepErr = withExitCode(exitApply, fmt.Errorf("auto-configuration failed: %w", err))
+		}
+	}
+
+	if s.exportFormat != "" {
+		maskOut := io.Writer(os.Stdout)
+		if jsonOutput() {
+			maskOut = os.Stderr
+		}
+		vars := exportVarsFor(result.BaseURL, resp.Key, result.Model)
+		if _, err := writeExport(s.exportFormat, s.exportPath, vars, maskOut); err != nil {
+			if stepErr == nil {
+				stepErr = withExitCode(exitApply, fmt.Errorf("could not write %s export: %w", s.exportFormat, err))
+			}
+		} else {
+			result.Export = &exportResult{Format: s.exportFormat, Path: s.exportPath}
+			warnState(recordExport(resp.ID, toolID, s.exportFormat, s.exportPath))
+			if s.exportPath != "-" {
+				fmt.Fprintf(out, "Exported configuration (%s) to %s\n", s.exportFormat, s.exportPath)
+			}
+		}
+	}
+	return result, resp.Key, stepErr
+}
+
+func printInitSummary(out io.Writer, results []initResult) {
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Summary:")
+	for _, r := range results {
+		status := "key " + r.KeyMasked
+		if r.KeyID == "" {
+			status = "FAILED: " + r.Error
+		} else if r.Apply != nil {
+			status += ", apply " + r.Apply.Status
+			if r.Apply.UpdatedPath != "" {
+				status += " (" + r.Apply.UpdatedPath + ")"
+			}
+		}
+		if r.KeyID != "" && r.Error != "" && (r.Apply == nil || r.Apply.Status != "failed") {
+			status += ", " + r.Error
+		}
+		fmt.Fprintf(out, "  %-18s %s\n", r.Tool, status)
+	}
+	fmt.Fprintln(out, "")
+}
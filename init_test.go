@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestToolListFlag(t *testing.T) {
+	t.Parallel()
+
+	var tools toolListFlag
+	for _, v := range []string{"cursor, claude_code_cli", "codex_cli", "cursor", ","} {
+		if err := tools.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"cursor", "claude_code_cli", "codex_cli"}
+	if len(tools) != len(want) {
+		t.Fatalf("tools = %v, want %v", tools, want)
+	}
+	for i := range want {
+		if tools[i] != want[i] {
+			t.Fatalf("tools = %v, want %v", tools, want)
+		}
+	}
+}
+
+func TestSetupToolContinuesAfterFailure(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SHELL", "/bin/bash")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/workspaces/ws_1/cli-keys" {
+			http.NotFound(w, r)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		var req createCliKeyReq
+		_ = json.Unmarshal(raw, &req)
+		if req.ToolID == "codex_cli" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(apiError{Error: "tool not enabled for workspace"})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(createCliKeyResp{
+			ID:     "key_" + req.ToolID,
+			Key:    "a21e_live_" + req.ToolID + "_0123456789",
+			Prefix: "a21e_live_",
+			ToolID: req.ToolID,
+		})
+	}))
+	defer srv.Close()
+
+	settings := initSettings{apiKey: "a21e_admin", baseURL: srv.URL, workspace: "ws_1", scope: "user", model: defaultModel, apply: true}
+
+	var failed, succeeded int
+	for i, id := range []string{"codex_cli", "openai_cli_custom", "claude_code_cli"} {
+		result, key, err := setupTool(settings, id, i == 1, io.Discard)
+		switch id {
+		case "codex_cli":
+			if err == nil || key != "" || exitCodeFor(err) != exitAuth {
+				t.Fatalf("codex_cli: expected auth failure, got key=%q err=%v", key, err)
+			}
+			failed++
+		case "openai_cli_custom":
+			if err != nil || result.Apply == nil || result.Apply.Status != "applied" {
+				t.Fatalf("openai_cli_custom: expected applied, got %+v err=%v", result.Apply, err)
+			}
+			succeeded++
+		case "claude_code_cli":
+			if err != nil || result.Apply == nil || result.Apply.Status != "unsupported" {
+				t.Fatalf("claude_code_cli: expected unsupported apply, got %+v err=%v", result.Apply, err)
+			}
+			succeeded++
+		}
+	}
+	if failed != 1 || succeeded != 2 {
+		t.Fatalf("failed=%d succeeded=%d, want 1 and 2", failed, succeeded)
+	}
+	if got := readCredentialsFile(); got != "a21e_live_openai_cli_custom_0123456789" {
+		t.Fatalf("credentials file holds %q, want the first created key", got)
+	}
+}
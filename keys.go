@@ -0,0 +1,68 @@
+// keys.go — "a21e keys" commands for the locally saved API key.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maskKey returns a display-safe form of an API key: its prefix plus the last 4 characters.
+// Keys too short to keep at least maskMinHidden characters hidden are masked entirely.
+func maskKey(key string) string {
+	prefix := keyPrefixFromRaw(key)
+	if len(key) <= len(prefix)+4+maskMinHidden {
+		return strings.Repeat("*", len(key))
+	}
+	return prefix + "…" + key[len(key)-4:]
+}
+
+// maskMinHidden is the fewest key characters maskKey may leave hidden.
+const maskMinHidden = 8
+
+// displayKey returns the key as it should be shown to the user.
+func displayKey(key string, show bool) string {
+	if show {
+		return key
+	}
+	return maskKey(key)
+}
+
+type keyRevealResult struct {
+	Prefix    string `json:"prefix"`
+	KeyMasked string `json:"key_masked"`
+	Copied    bool   `json:"copied"`
+	Helper    string `json:"helper"`
+}
+
+func newKeysRevealFlagSet() *flag.FlagSet {
+	return flag.NewFlagSet("keys reveal", flag.ContinueOnError)
+}
+
+func runKeysReveal(args []string) {
+	fs := newKeysRevealFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+
+	key := readCredentialsFile()
+	if key == "" {
+		exitWithError("keys reveal", withExitCode(exitAuth, errors.New("no saved key in ~/.a21e/credentials; run a21e init first")))
+	}
+	helper, err := copyToClipboard(key)
+	if err != nil {
+		exitWithError("keys reveal", err)
+	}
+
+	if jsonOutput() {
+		writeJSON(keyRevealResult{Prefix: keyPrefixFromRaw(key), KeyMasked: maskKey(key), Copied: true, Helper: helper})
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Copied %s to the clipboard (via %s).\n", maskKey(key), helper)
+}
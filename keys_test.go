@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMaskKey(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "prefix and last four", key: "a21e_live_abcdefghijklmnop1234", want: "a21e_live_ab…1234"},
+		{name: "short keys are fully hidden", key: "a21e_short", want: "**********"},
+		{name: "too little left hidden", key: "a21e_live_abcdefgh1234", want: "**********************"},
+		{name: "empty", key: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := maskKey(tc.key); got != tc.want {
+				t.Fatalf("maskKey(%q) = %q, want %q", tc.key, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCopyToClipboardUsesHelper(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fake helper is a shell script")
+	}
+	dir := t.TempDir()
+	captured := filepath.Join(dir, "captured")
+	script := "#!/bin/sh\ncat > " + captured + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// wl-copy is found but fails without a compositor; xclip should be tried next.
+	if err := os.WriteFile(filepath.Join(dir, "wl-copy"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+
+	helper, err := copyToClipboard("a21e_secret")
+	if err != nil {
+		t.Fatalf("copyToClipboard returned unexpected error: %v", err)
+	}
+	if helper != "xclip" {
+		t.Fatalf("helper = %q, want xclip", helper)
+	}
+	got, err := os.ReadFile(captured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "a21e_secret" {
+		t.Fatalf("clipboard received %q, want %q", got, "a21e_secret")
+	}
+
+	os.Remove(filepath.Join(dir, "xclip"))
+	t.Setenv("PATH", dir)
+	if _, err := copyToClipboard("a21e_secret"); err == nil || !strings.Contains(err.Error(), "wl-copy") {
+		t.Fatalf("expected the wl-copy failure, got %v", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := copyToClipboard("a21e_secret"); err != errNoClipboardHelper {
+		t.Fatalf("expected errNoClipboardHelper, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+// loopback.go — CLI browser login via OAuth authorization code + PKCE on a 127.0.0.1 redirect.
+//
+// When the browser runs on the same machine as the CLI, a loopback redirect is much
+// faster than device polling: the browser hands the code straight back to us.
+// If the browser cannot be opened we fall back to the device flow (device.go).
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const loopbackTimeout = 5 * time.Minute
+const loopbackClientID = "a21e-cli"
+
+var errBrowserUnavailable = errors.New("could not open a browser on this machine")
+
+type loopbackTokenReq struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+	RedirectURI  string `json:"redirect_uri"`
+}
+
+type loopbackTokenResp struct {
+	APIKey string `json:"api_key"`
+}
+
+type loopbackCallback struct {
+	code string
+	err  error
+}
+
+// loginWithBrowser signs the user in, preferring the loopback redirect and falling
+// back to device polling when no browser can be opened. Returns the API key.
+func loginWithBrowser(baseURL string, forceDevice bool) (string, error) {
+	if !forceDevice {
+		key, err := startLoopbackFlow(baseURL)
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, errBrowserUnavailable) {
+			return "", err
+		}
+		fmt.Fprintln(os.Stderr, "Could not open a browser here; using device login instead.")
+	}
+	return startDeviceFlow(baseURL)
+}
+
+// startLoopbackFlow runs the authorization code + PKCE flow with the system browser.
+func startLoopbackFlow(baseURL string) (string, error) {
+	return runLoopbackFlow(baseURL, openBrowser, loopbackTimeout)
+}
+
+// runLoopbackFlow listens on 127.0.0.1, sends the user to the authorize URL via open,
+// waits for the redirect and exchanges the code for an API key.
+func runLoopbackFlow(baseURL string, open func(string) error, timeout time.Duration) (string, error) {
+	verifier, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomURLToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("could not start local callback listener: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://%s/callback", ln.Addr().String())
+
+	results := make(chan loopbackCallback, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			// Not our redirect: any local process can reach this port, so a stray
+			// request must not end the sign-in. Keep waiting for the real one.
+			http.Error(w, "unexpected state", http.StatusBadRequest)
+			return
+		}
+		var cb loopbackCallback
+		switch {
+		case q.Get("error") != "":
+			msg := q.Get("error")
+			if d := q.Get("error_description"); d != "" {
+				msg += ": " + d
+			}
+			cb.err = fmt.Errorf("authorization denied: %s", msg)
+		case q.Get("code") == "":
+			cb.err = errors.New("authorization callback did not include a code")
+		default:
+			cb.code = q.Get("code")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if cb.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<html><body><p>a21e sign-in failed. Return to your terminal for details.</p></body></html>")
+		} else {
+			fmt.Fprint(w, "<html><body><p>a21e sign-in complete. You can close this window.</p></body></html>")
+		}
+		select {
+		case results <- cb:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	authURL := loopbackAuthorizeURL(baseURL, redirectURI, pkceChallenge(verifier), state)
+	if err := open(authURL); err != nil {
+		return "", fmt.Errorf("%w: %v", errBrowserUnavailable, err)
+	}
+	fmt.Fprintf(os.Stderr, "\nYour browser has been opened to sign in. If it did not open, visit:\n\n  %s\n\n", authURL)
+
+	var cb loopbackCallback
+	select {
+	case cb = <-results:
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timed out waiting for authorization")
+	}
+	if cb.err != nil {
+		return "", cb.err
+	}
+	return exchangeLoopbackCode(baseURL, cb.code, verifier, redirectURI)
+}
+
+func loopbackAuthorizeURL(baseURL, redirectURI, challenge, state string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", loopbackClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	q.Set("state", state)
+	return endpointsFor(baseURL).API + "/v1/cli/authorize?" + q.Encode()
+}
+
+func exchangeLoopbackCode(baseURL, code, verifier, redirectURI string) (string, error) {
+	req := loopbackTokenReq{
+		GrantType:    "authorization_code",
+		ClientID:     loopbackClientID,
+		Code:         code,
+		CodeVerifier: verifier,
+		RedirectURI:  redirectURI,
+	}
+	raw, status, err := apiRequest("", baseURL, "POST", "/v1/cli/token", req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		return "", newAPIStatusError(status, raw)
+	}
+	var tok loopbackTokenResp
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	if tok.APIKey == "" {
+		return "", errors.New("token response did not include an API key")
+	}
+	return tok.APIKey, nil
+}
+
+// pkceChallenge returns the S256 code challenge for verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomURLToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer implements the authorize redirect and token exchange of the a21e auth API.
+func fakeAuthServer(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cli/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		challenge = q.Get("code_challenge")
+		mu.Unlock()
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		rq := redirect.Query()
+		rq.Set("code", "test-code")
+		rq.Set("state", q.Get("state"))
+		redirect.RawQuery = rq.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/v1/cli/token", func(w http.ResponseWriter, r *http.Request) {
+		var req loopbackTokenReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		want := challenge
+		mu.Unlock()
+		if req.Code != "test-code" || pkceChallenge(req.CodeVerifier) != want {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(apiError{Error: "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(loopbackTokenResp{APIKey: apiKey})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// followingBrowser simulates the user's browser: it loads the authorize URL and follows redirects.
+func followingBrowser(target string) error {
+	go func() {
+		resp, err := http.Get(target)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	return nil
+}
+
+func TestRunLoopbackFlow(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeAuthServer(t, "a21e_loopback_key")
+	key, err := runLoopbackFlow(srv.URL, followingBrowser, 5*time.Second)
+	if err != nil {
+		t.Fatalf("runLoopbackFlow returned unexpected error: %v", err)
+	}
+	if key != "a21e_loopback_key" {
+		t.Fatalf("runLoopbackFlow key = %q, want %q", key, "a21e_loopback_key")
+	}
+}
+
+func TestRunLoopbackFlowBrowserUnavailable(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeAuthServer(t, "unused")
+	_, err := runLoopbackFlow(srv.URL, func(string) error { return errors.New("no xdg-open") }, time.Second)
+	if !errors.Is(err, errBrowserUnavailable) {
+		t.Fatalf("expected errBrowserUnavailable, got %v", err)
+	}
+}
+
+func TestRunLoopbackFlowIgnoresStateMismatch(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeAuthServer(t, "a21e_loopback_key")
+	strayStatus := make(chan int, 1)
+	tamper := func(target string) error {
+		u, _ := url.Parse(target)
+		redirect := u.Query().Get("redirect_uri")
+		go func() {
+			// A stray local request with the wrong state arrives first; the flow must
+			// reject it and keep waiting for the browser's redirect.
+			resp, err := http.Get(redirect + "?code=stolen&state=wrong")
+			if err != nil {
+				strayStatus <- 0
+				return
+			}
+			resp.Body.Close()
+			strayStatus <- resp.StatusCode
+			_ = followingBrowser(target)
+		}()
+		return nil
+	}
+	key, err := runLoopbackFlow(srv.URL, tamper, 5*time.Second)
+	if err != nil || key != "a21e_loopback_key" {
+		t.Fatalf("runLoopbackFlow = %q, %v; want the key from the real callback", key, err)
+	}
+	if code := <-strayStatus; code != http.StatusBadRequest {
+		t.Fatalf("stray callback status = %d, want 400", code)
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	t.Parallel()
+
+	// Test vector from RFC 7636 appendix B.
+	got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got != want {
+		t.Fatalf("pkceChallenge = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,446 @@
+// manifest.go — "a21e apply -f a21e.yaml": reconcile this machine with a team setup file.
+//
+// Example manifest:
+//
+//	version: 1
+//	workspace: ws_123        # optional; the default workspace otherwise
+//	scope: user              # default key scope for every tool: user or workspace
+//	model: a21e-auto         # default model for every tool (a21e apply --model overrides)
+//	tools:
+//	  - cursor               # shorthand: default scope, apply when supported
+//	  - id: vscode
+//	    scope: workspace
+//	    model: gpt-4o        # pin a model for this tool
+//	  - id: claude_code_cli
+//	    export:
+//	      format: dotenv     # dotenv, gitlab, shell or github-actions
+//	      file: .env.claude
+//	  - id: openai_cli_custom
+//	    apply: false
+//	    export:
+//	      format: shell
+//	      file: openai-env.sh
+//
+// For every tool, apply finds the key the tool is configured with (its apply target,
+// else its export file), keeps it if it is still active, creates a key otherwise, and
+// re-applies or re-exports when the target no longer matches. A second run with no
+// drift changes nothing.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultManifestPath = "a21e.yaml"
+
+type manifest struct {
+	Version   int            `json:"version"`
+	Workspace string         `json:"workspace"`
+	Scope     string         `json:"scope"`
+	Model     string         `json:"model"`
+	Tools     []manifestTool `json:"tools"`
+}
+
+type manifestTool struct {
+	ID     string          `json:"id"`
+	Scope  string          `json:"scope"`
+	Model  string          `json:"model"` // default: the manifest's model
+	Apply  *bool           `json:"apply"` // default: true when the tool supports --apply
+	Export *manifestExport `json:"export"`
+}
+
+type manifestExport struct {
+	Format string `json:"format"`
+	File   string `json:"file"`
+}
+
+// UnmarshalJSON accepts a bare tool ID as shorthand for {id: <tool>}.
+func (t *manifestTool) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*t = manifestTool{ID: id}
+		return nil
+	}
+	type plain manifestTool
+	var p plain
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return err
+	}
+	*t = manifestTool(p)
+	return nil
+}
+
+func loadManifest(path string) (*manifest, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := decodeYAML(raw, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// validate checks the manifest and fills in defaults.
+func (m *manifest) validate() error {
+	if m.Version == 0 {
+		m.Version = 1
+	}
+	if m.Version != 1 {
+		return fmt.Errorf("unsupported manifest version %d (want 1)", m.Version)
+	}
+	if m.Scope == "" {
+		m.Scope = "user"
+	}
+	if !isValidScope(m.Scope) {
+		return fmt.Errorf("invalid scope %q (want user or workspace)", m.Scope)
+	}
+	if len(m.Tools) == 0 {
+		return errors.New("manifest lists no tools")
+	}
+	seen := map[string]bool{}
+	exportedBy := map[string]string{} // export file -> tool ID; each file holds one managed block
+	for i := range m.Tools {
+		t := &m.Tools[i]
+		tool, ok := lookupTool(t.ID)
+		if !ok {
+			return fmt.Errorf("tools[%d]: invalid tool_id %q. Supported: %s", i, t.ID, strings.Join(validToolIDs, ", "))
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("tools[%d]: %s is listed twice", i, t.ID)
+		}
+		seen[t.ID] = true
+		if t.Scope == "" {
+			t.Scope = m.Scope
+		}
+		if !isValidScope(t.Scope) {
+			return fmt.Errorf("tools[%d]: invalid scope %q (want user or workspace)", i, t.Scope)
+		}
+		supported := tool.Doc().AutoApply != ""
+		if t.Apply == nil {
+			t.Apply = &supported
+		} else if *t.Apply && !supported {
+			return fmt.Errorf("tools[%d]: %s cannot be auto-applied; set apply: false or add an export", i, t.ID)
+		}
+		if !*t.Apply && t.Export == nil {
+			return fmt.Errorf("tools[%d]: %s has nowhere to keep its key; enable apply or add an export", i, t.ID)
+		}
+		if t.Export != nil {
+			path, err := resolveExportTarget(t.Export.Format, t.Export.File)
+			if err != nil {
+				return fmt.Errorf("tools[%d]: %w", i, err)
+			}
+			if path == "-" {
+				return fmt.Errorf("tools[%d]: export format %s needs a file", i, t.Export.Format)
+			}
+			t.Export.File = path
+			key := path
+			if abs, err := filepath.Abs(path); err == nil {
+				key = abs
+			}
+			if other, ok := exportedBy[key]; ok {
+				return fmt.Errorf("tools[%d]: %s exports to %s, which %s already uses; give one of them another file", i, t.ID, path, other)
+			}
+			exportedBy[key] = t.ID
+		}
+	}
+	return nil
+}
+
+func isValidScope(scope string) bool {
+	return scope == "user" || scope == "workspace"
+}
+
+// reconcileResult is what apply did (or would do, with --dry-run) for one tool.
+type reconcileResult struct {
+	Tool    string   `json:"tool"`
+	KeyID   string   `json:"key_id,omitempty"`
+	Key     string   `json:"key_masked,omitempty"`
+	Changes []string `json:"changes"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type reconcileReport struct {
+	Manifest  string            `json:"manifest"`
+	Workspace string            `json:"workspace"`
+	DryRun    bool              `json:"dry_run"`
+	Tools     []reconcileResult `json:"tools"`
+	Changed   int               `json:"changed"`
+	Failed    int               `json:"failed"`
+}
+
+// reconciler holds what every tool's reconciliation needs.
+type reconciler struct {
+	apiKey     string
+	baseURL    string
+	workspace  string
+	dryRun     bool
+	model      string // for tools without their own model
+	activeKeys []apiKeyListItem
+	stdout     io.Writer // for github-actions masking commands
+}
+
+// applyFlags are the options of a21e apply.
+type applyFlags struct {
+	file           *string
+	dryRun         *bool
+	nonInteractive *bool
+	deviceLogin    *bool
+	model          *string
+}
+
+func newApplyFlagSet() (*flag.FlagSet, applyFlags) {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	return fs, applyFlags{
+		file:           fs.String("f", defaultManifestPath, "Manifest file to apply"),
+		dryRun:         fs.Bool("dry-run", false, "Report what would change without creating keys or writing files"),
+		nonInteractive: fs.Bool("non-interactive", false, "CI/non-interactive mode"),
+		deviceLogin:    fs.Bool("device", false, "Use device code login instead of the local browser redirect"),
+		model:          fs.String("model", "", "Model for tools that do not pin one (overrides the manifest's model)"),
+	}
+}
+
+func runApply(args []string) {
+	fs, f := newApplyFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+
+	m, err := loadManifest(*f.file)
+	if err != nil {
+		exitWithError("apply", withExitCode(exitValidation, err))
+	}
+
+	out := humanOut()
+	baseURL := getAPIBaseURL()
+	apiKey, bootstrapKey := ensureAPIKey("apply", baseURL, *f.nonInteractive, *f.deviceLogin, out)
+
+	defaultToolModel := settingValue("model")
+	for _, candidate := range []string{*f.model, m.Model} {
+		if candidate != "" {
+			defaultToolModel = candidate
+			break
+		}
+	}
+	models := []string{defaultToolModel}
+	for _, entry := range m.Tools {
+		models = append(models, entry.Model)
+	}
+	if err := checkModels(openAIBaseURL(baseURL), apiKey, models...); err != nil {
+		exitWithError("apply", err)
+	}
+
+	wid := m.Workspace
+	if wid == "" {
+		ws, err := getDefaultWorkspace(apiKey, baseURL)
+		if err != nil {
+			exitWithError("apply", err)
+		}
+		wid = ws.ID
+	}
+	active, err := listAPIKeysForUser(apiKey, baseURL)
+	if err != nil {
+		exitWithError("apply", err)
+	}
+
+	r := &reconciler{apiKey: apiKey, baseURL: baseURL, workspace: wid, dryRun: *f.dryRun, model: defaultToolModel, activeKeys: active, stdout: os.Stdout}
+	if jsonOutput() {
+		r.stdout = os.Stderr
+	}
+	report := reconcileReport{Manifest: *f.file, Workspace: wid, DryRun: *f.dryRun}
+	exitCode := exitOK
+	firstKey := ""
+	for _, entry := range m.Tools {
+		res, key, err := r.reconcileTool(entry)
+		if err != nil {
+			res.Error = err.Error()
+			report.Failed++
+			if exitCode == exitOK {
+				exitCode = exitCodeFor(err)
+			}
+		}
+		if len(res.Changes) > 0 {
+			report.Changed++
+		}
+		if firstKey == "" {
+			firstKey = key
+		}
+		report.Tools = append(report.Tools, res)
+	}
+
+	if bootstrapKey != "" && !*f.dryRun {
+		// The bootstrap key stays in ~/.a21e/credentials unless a tool key replaced it.
+		if firstKey == "" {
+			fmt.Fprintln(out, "No tool key was created; keeping the sign-in key in ~/.a21e/credentials.")
+		} else if err := writeCredentialsFile(firstKey); err != nil {
+			fmt.Fprintf(out, "a21e apply: could not save key to file: %v\n", err)
+		} else if err := revokeBootstrapKeyIfPresent(bootstrapKey, baseURL, bootstrapKey); err != nil {
+			fmt.Fprintf(out, "a21e apply: warning: could not revoke temporary bootstrap key: %v\n", err)
+		}
+	}
+
+	if jsonOutput() {
+		writeJSON(report)
+	} else {
+		printReconcileReport(report)
+	}
+	os.Exit(exitCode)
+}
+
+// reconcileTool brings one tool in line with entry. It returns the key the tool ends
+// up with ("" in dry-run when a key would be created).
+func (r *reconciler) reconcileTool(entry manifestTool) (reconcileResult, string, error) {
+	res := reconcileResult{Tool: entry.ID, Changes: []string{}}
+	tool, _ := lookupTool(entry.ID)
+	verb := func(done, planned string) string {
+		if r.dryRun {
+			return planned
+		}
+		return done
+	}
+
+	key, err := r.existingKey(tool, entry)
+	if err != nil {
+		return res, "", err
+	}
+	if key != "" {
+		if item := findActiveKey(r.activeKeys, key); item != nil {
+			res.KeyID = item.ID
+		} else {
+			res.Changes = append(res.Changes, fmt.Sprintf("configured key %s is no longer active", maskKey(key)))
+			key = ""
+		}
+	}
+
+	created := false
+	if key == "" {
+		if r.dryRun {
+			res.Changes = append(res.Changes, fmt.Sprintf("would create %s-scoped key", entry.Scope))
+			return res, "", nil
+		}
+		resp, err := createCLIKey(r.apiKey, r.baseURL, r.workspace, entry.ID, suggestLabel(entry.ID), entry.Scope)
+		if err != nil {
+			return res, "", err
+		}
+		key = resp.Key
+		res.KeyID = resp.ID
+		created = true
+		warnState(recordKey(resp, entry.ID, r.workspace, entry.Scope))
+		res.Changes = append(res.Changes, fmt.Sprintf("created %s-scoped key %s", entry.Scope, maskKey(key)))
+	}
+	res.Key = maskKey(key)
+	model := r.modelFor(entry)
+
+	if *entry.Apply {
+		report, err := tool.Verify(key, r.baseURL, model)
+		if err != nil {
+			return res, key, withExitCode(exitApply, err)
+		}
+		if len(report.Drift) > 0 || created {
+			if !r.dryRun {
+				summary, err := tool.Apply(key, r.baseURL, model)
+				if err != nil {
+					return res, key, withExitCode(exitApply, err)
+				}
+				warnState(recordApply(res.KeyID, entry.ID, summary))
+			}
+			change := verb("applied ", "would apply ") + report.Path
+			if !created {
+				change += " (" + strings.Join(report.Drift, "; ") + ")"
+			}
+			res.Changes = append(res.Changes, change)
+		}
+	}
+
+	if entry.Export != nil {
+		currentKey, currentModel, err := readExportedValues(entry.Export.Format, entry.Export.File)
+		if err != nil {
+			return res, key, withExitCode(exitApply, err)
+		}
+		if currentKey != key || currentModel != model || entry.Export.Format == "github-actions" {
+			if !r.dryRun {
+				vars := exportVarsFor(openAIBaseURL(r.baseURL), key, model)
+				if _, err := writeExport(entry.Export.Format, entry.Export.File, vars, r.stdout); err != nil {
+					return res, key, withExitCode(exitApply, err)
+				}
+				warnState(recordExport(res.KeyID, entry.ID, entry.Export.Format, entry.Export.File))
+			}
+			res.Changes = append(res.Changes, fmt.Sprintf("%s %s (%s)", verb("exported to", "would export to"), entry.Export.File, entry.Export.Format))
+		}
+	}
+	return res, key, nil
+}
+
+// existingKey returns the key tool is configured with: from its apply target when
+// the manifest applies it, else from its export file.
+func (r *reconciler) existingKey(tool Tool, entry manifestTool) (string, error) {
+	if *entry.Apply {
+		key, err := tool.CurrentKey()
+		if err != nil || key != "" {
+			return key, err
+		}
+	}
+	if entry.Export != nil {
+		key, _, err := readExportedValues(entry.Export.Format, entry.Export.File)
+		return key, err
+	}
+	return "", nil
+}
+
+// modelFor returns the model entry pins, else the manifest-wide one.
+func (r *reconciler) modelFor(entry manifestTool) string {
+	if entry.Model != "" {
+		return entry.Model
+	}
+	if r.model != "" {
+		return r.model
+	}
+	return defaultModel
+}
+
+// findActiveKey returns the entry of items for key, or nil when the server does not
+// list key as active.
+func findActiveKey(items []apiKeyListItem, key string) *apiKeyListItem {
+	prefix := keyPrefixFromRaw(key)
+	for i, item := range items {
+		if item.KeyPrefix == prefix && (item.IsActive == nil || *item.IsActive) {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+func printReconcileReport(report reconcileReport) {
+	fmt.Fprintf(os.Stderr, "Workspace: %s\n", report.Workspace)
+	if report.DryRun {
+		fmt.Fprintln(os.Stderr, "Dry run: nothing was changed.")
+	}
+	for _, t := range report.Tools {
+		switch {
+		case t.Error != "":
+			fmt.Fprintf(os.Stderr, "  %-18s FAILED: %s\n", t.Tool, t.Error)
+		case len(t.Changes) == 0:
+			fmt.Fprintf(os.Stderr, "  %-18s up to date\n", t.Tool)
+		default:
+			fmt.Fprintf(os.Stderr, "  %-18s %s\n", t.Tool, strings.Join(t.Changes, "; "))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d tools changed, %d failed.\n", report.Changed, len(report.Tools), report.Failed)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeKeyServer issues CLI keys and lists them, like the a21e API.
+type fakeKeyServer struct {
+	mu   sync.Mutex
+	keys []apiKeyListItem
+}
+
+func (f *fakeKeyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/api-keys":
+		_ = json.NewEncoder(w).Encode(f.keys)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/cli-keys"):
+		var req createCliKeyReq
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		n := len(f.keys) + 1
+		key := fmt.Sprintf("a21e_k%05d_%s_secret", n, req.ToolID)
+		active := true
+		f.keys = append(f.keys, apiKeyListItem{ID: fmt.Sprintf("key_%d", n), KeyPrefix: keyPrefixFromRaw(key), IsActive: &active})
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(createCliKeyResp{ID: fmt.Sprintf("key_%d", n), Key: key, ToolID: req.ToolID})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeKeyServer) created() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.keys)
+}
+
+func TestReconcileIsIdempotent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/zsh")
+
+	exportPath := filepath.Join(home, "claude.env")
+	manifestPath := filepath.Join(home, "a21e.yaml")
+	manifestYAML := "workspace: ws_1\ntools:\n  - cursor\n  - id: openai_cli_custom\n    scope: workspace\n  - id: claude_code_cli\n    export:\n      format: dotenv\n      file: " + exportPath + "\n"
+	if err := os.WriteFile(manifestPath, []byte(manifestYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("loadManifest returned unexpected error: %v", err)
+	}
+
+	fake := &fakeKeyServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	run := func() []reconcileResult {
+		t.Helper()
+		active, err := listAPIKeysForUser("admin", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &reconciler{apiKey: "admin", baseURL: srv.URL, workspace: "ws_1", activeKeys: active, stdout: io.Discard}
+		var results []reconcileResult
+		for _, entry := range m.Tools {
+			res, _, err := r.reconcileTool(entry)
+			if err != nil {
+				t.Fatalf("%s: reconcileTool returned unexpected error: %v", entry.ID, err)
+			}
+			results = append(results, res)
+		}
+		return results
+	}
+
+	for _, res := range run() {
+		if len(res.Changes) == 0 {
+			t.Fatalf("first run: expected changes for %s", res.Tool)
+		}
+	}
+	if fake.created() != 3 {
+		t.Fatalf("first run created %d keys, want 3", fake.created())
+	}
+
+	for _, res := range run() {
+		if len(res.Changes) != 0 {
+			t.Fatalf("second run: expected no changes for %s, got %v", res.Tool, res.Changes)
+		}
+	}
+	if fake.created() != 3 {
+		t.Fatalf("second run created keys; total %d, want 3", fake.created())
+	}
+
+	// Someone edits the managed Cursor setting: the next run re-applies the same key.
+	cursor, _ := lookupTool("cursor")
+	key, err := cursor.CurrentKey()
+	if err != nil || key == "" {
+		t.Fatalf("cursor CurrentKey = %q, %v", key, err)
+	}
+	settingsPath, _ := cursor.Target()
+	raw, _ := os.ReadFile(settingsPath)
+	if err := os.WriteFile(settingsPath, []byte(strings.Replace(string(raw), "a21e-auto", "gpt-4o", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	results := run()
+	if len(results[0].Changes) != 1 || !strings.Contains(results[0].Changes[0], "a21e.defaultModel") {
+		t.Fatalf("drift run: unexpected cursor changes %v", results[0].Changes)
+	}
+	if fake.created() != 3 {
+		t.Fatalf("drift run created a new key; total %d, want 3", fake.created())
+	}
+}
+
+func TestReconcilePinnedModel(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	exportPath := filepath.Join(home, "codex.env")
+	apply := true
+	noApply := false
+	entries := []manifestTool{
+		{ID: "cursor", Scope: "user", Model: "gpt-4o", Apply: &apply},
+		{ID: "codex_cli", Scope: "user", Apply: &noApply, Export: &manifestExport{Format: "dotenv", File: exportPath}},
+	}
+
+	fake := &fakeKeyServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	run := func(model string) []reconcileResult {
+		t.Helper()
+		active, err := listAPIKeysForUser("admin", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &reconciler{apiKey: "admin", baseURL: srv.URL, workspace: "ws_1", model: model, activeKeys: active, stdout: io.Discard}
+		var results []reconcileResult
+		for _, entry := range entries {
+			res, _, err := r.reconcileTool(entry)
+			if err != nil {
+				t.Fatalf("%s: %v", entry.ID, err)
+			}
+			results = append(results, res)
+		}
+		return results
+	}
+
+	run("")
+	cursor, _ := lookupTool("cursor")
+	settingsPath, _ := cursor.Target()
+	raw, _ := os.ReadFile(settingsPath)
+	if !strings.Contains(string(raw), `"a21e.defaultModel": "gpt-4o"`) {
+		t.Fatalf("cursor settings do not pin gpt-4o:\n%s", raw)
+	}
+	if _, model, _ := readExportedValues("dotenv", exportPath); model != defaultModel {
+		t.Fatalf("exported model = %q, want %q", model, defaultModel)
+	}
+
+	// Changing the manifest-wide model re-exports codex but leaves the pinned cursor alone.
+	results := run("o3-mini")
+	if len(results[0].Changes) != 0 {
+		t.Errorf("cursor changed: %v", results[0].Changes)
+	}
+	if len(results[1].Changes) != 1 {
+		t.Errorf("codex changes = %v, want one re-export", results[1].Changes)
+	}
+	if _, model, _ := readExportedValues("dotenv", exportPath); model != "o3-mini" {
+		t.Errorf("exported model = %q, want o3-mini", model)
+	}
+	if fake.created() != 2 {
+		t.Errorf("created %d keys, want 2", fake.created())
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{name: "unknown tool", yaml: "tools: [emacs]\n", want: "invalid tool_id"},
+		{name: "duplicate tool", yaml: "tools: [cursor, cursor]\n", want: "listed twice"},
+		{name: "manual tool without export", yaml: "tools: [codex_cli]\n", want: "nowhere to keep its key"},
+		{name: "apply on manual tool", yaml: "tools:\n  - id: jetbrains\n    apply: true\n", want: "cannot be auto-applied"},
+		{name: "shared export file", yaml: "tools:\n  - id: codex_cli\n    export:\n      format: dotenv\n  - id: claude_code_cli\n    export:\n      format: dotenv\n      file: ./.env\n", want: "already uses"},
+		{name: "bad scope", yaml: "scope: team\ntools: [cursor]\n", want: "invalid scope"},
+		{name: "unknown field", yaml: "tools:\n  - id: cursor\n    aply: true\n", want: "unknown field"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "a21e.yaml")
+			if err := os.WriteFile(path, []byte(tc.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadManifest(path)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("loadManifest error = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
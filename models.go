@@ -0,0 +1,126 @@
+// models.go — `a21e models`, and checking a --model choice before it is written.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+type modelInfo struct {
+	ID      string `json:"id"`
+	OwnedBy string `json:"owned_by,omitempty"`
+	Created int64  `json:"created,omitempty"`
+}
+
+type modelsOutput struct {
+	Default string      `json:"default"`
+	Models  []modelInfo `json:"models"`
+}
+
+// listModels returns the models the OpenAI-compatible API at openAIBase offers to key.
+func listModels(openAIBase, key string) ([]modelInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(openAIBase, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	setOpenAIAuth(req.Header, key)
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newOpenAIStatusError(resp.StatusCode, raw)
+	}
+	var body struct {
+		Data []modelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("invalid models response: %w", err)
+	}
+	sort.Slice(body.Data, func(i, j int) bool { return body.Data[i].ID < body.Data[j].ID })
+	return body.Data, nil
+}
+
+// checkModels verifies that every model is available to key before it is written
+// into a tool's configuration. The built-in default is always accepted, so plain
+// init and apply never need the models endpoint.
+func checkModels(openAIBase, key string, models ...string) error {
+	var pinned []string
+	for _, m := range models {
+		if m != defaultModel {
+			pinned = append(pinned, m)
+		}
+	}
+	if len(pinned) == 0 {
+		return nil
+	}
+	available, err := listModels(openAIBase, key)
+	if err != nil {
+		return fmt.Errorf("could not list models to check --model: %w", err)
+	}
+	ids := make(map[string]bool, len(available))
+	for _, m := range available {
+		ids[m.ID] = true
+	}
+	for _, m := range pinned {
+		if !ids[m] {
+			return withExitCode(exitValidation, fmt.Errorf("model %q is not available to this key (run a21e models to list them)", m))
+		}
+	}
+	return nil
+}
+
+func newModelsFlagSet() (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	return fs, fs.Bool("json", false, "Print the list as JSON (same as --output json)")
+}
+
+func runModels(args []string) {
+	fs, asJSON := newModelsFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *asJSON {
+		outputMode = outputJSON
+	}
+
+	apiKey := requireAPIKey("models")
+	models, err := listModels(openAIBaseURL(getAPIBaseURL()), apiKey)
+	if err != nil {
+		exitWithError("models", err)
+	}
+	if jsonOutput() {
+		writeJSON(modelsOutput{Default: defaultModel, Models: models})
+		return
+	}
+	if len(models) == 0 {
+		fmt.Fprintln(os.Stderr, "No models are available to this key.")
+		return
+	}
+	for _, m := range models {
+		line := m.ID
+		if m.OwnedBy != "" {
+			line = fmt.Sprintf("%-32s %s", m.ID, m.OwnedBy)
+		}
+		if m.ID == defaultModel {
+			line += "  (default)"
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
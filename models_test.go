@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckModels(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer k" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o","owned_by":"openai"},{"id":"a21e-auto","owned_by":"a21e"}]}`)
+	}))
+	defer srv.Close()
+	base := srv.URL + "/v1"
+
+	models, err := listModels(base, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].ID != "a21e-auto" || models[1].OwnedBy != "openai" {
+		t.Fatalf("models = %+v, want sorted by ID", models)
+	}
+
+	testCases := []struct {
+		name     string
+		models   []string
+		wantErr  bool
+		wantCall bool
+	}{
+		{name: "default needs no lookup", models: []string{defaultModel}},
+		{name: "available model", models: []string{defaultModel, "gpt-4o"}, wantCall: true},
+		{name: "unknown model", models: []string{"gpt-4o", "o9-ultra"}, wantErr: true, wantCall: true},
+	}
+	for _, tc := range testCases {
+		before := calls
+		err := checkModels(base, "k", tc.models...)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if tc.wantErr && exitCodeFor(err) != exitValidation {
+			t.Errorf("%s: exit code %d, want %d", tc.name, exitCodeFor(err), exitValidation)
+		}
+		if got := calls > before; got != tc.wantCall {
+			t.Errorf("%s: called models endpoint = %v, want %v", tc.name, got, tc.wantCall)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// openai.go — Client for the OpenAI-compatible API under openAIBaseURL.
+//
+// chat, complete and proxy talk to the same endpoints the configured tools use, with
+// the key sent both as a bearer token (what OpenAI clients send) and as X-API-Key.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultModel = "a21e-auto"
+
+// setOpenAIAuth replaces any credentials in h with key.
+func setOpenAIAuth(h http.Header, key string) {
+	h.Set("Authorization", "Bearer "+key)
+	h.Set("X-API-Key", key)
+}
+
+// newOpenAIStatusError reads an OpenAI-style {"error": {"message": ...}} body, and
+// falls back to the a21e API error shape.
+func newOpenAIStatusError(status int, raw []byte) error {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil && body.Error.Message != "" {
+		code := body.Error.Type
+		if c, ok := body.Error.Code.(string); ok && c != "" {
+			code = c
+		}
+		return &apiStatusError{Status: status, Code: code, Message: body.Error.Message}
+	}
+	return newAPIStatusError(status, raw)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	Stream    bool          `json:"stream"`
+	MaxTokens int           `json:"max_tokens,omitempty"`
+}
+
+// chatResult is a finished completion; Content is the whole assistant message.
+type chatResult struct {
+	Model        string `json:"model"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	LatencyMS    int64  `json:"latency_ms"`
+}
+
+// chatChunk covers both streamed chunks (delta) and full responses (message).
+type chatChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta        chatMessage `json:"delta"`
+		Message      chatMessage `json:"message"`
+		FinishReason *string     `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamChat sends a streaming chat completion to openAIBase and calls onDelta with
+// each piece of content as it arrives. Servers that ignore "stream" and answer with
+// a single JSON response are handled too.
+func streamChat(ctx context.Context, openAIBase, key string, req chatRequest, onDelta func(string)) (*chatResult, error) {
+	req.Stream = true
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(openAIBase, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	setOpenAIAuth(httpReq.Header, key)
+
+	start := time.Now()
+	resp, err := httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, newOpenAIStatusError(resp.StatusCode, raw)
+	}
+
+	result := &chatResult{Model: req.Model}
+	var content strings.Builder
+	apply := func(c *chatChunk) error {
+		if c.Error != nil {
+			return fmt.Errorf("stream error: %s", c.Error.Message)
+		}
+		if c.Model != "" {
+			result.Model = c.Model
+		}
+		for _, ch := range c.Choices {
+			piece := ch.Delta.Content + ch.Message.Content
+			if piece != "" {
+				content.WriteString(piece)
+				onDelta(piece)
+			}
+			if ch.FinishReason != nil {
+				result.FinishReason = *ch.FinishReason
+			}
+		}
+		return nil
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var c chatChunk
+		if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+			return nil, fmt.Errorf("invalid chat completion response: %w", err)
+		}
+		if err := apply(&c); err != nil {
+			return nil, err
+		}
+	} else if err := readSSE(resp.Body, func(data string) error {
+		var c chatChunk
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			return fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		return apply(&c)
+	}); err != nil {
+		return nil, err
+	}
+
+	result.Content = content.String()
+	result.LatencyMS = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+var errSSEDone = errors.New("done")
+
+// readSSE calls onData with the data of each server-sent event until [DONE] or EOF.
+func readSSE(r io.Reader, onData func(string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data []string
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		if payload == "[DONE]" {
+			return errSSEDone
+		}
+		return onData(payload)
+	}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return ignoreDone(err)
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return ignoreDone(flush())
+}
+
+func ignoreDone(err error) error {
+	if errors.Is(err, errSSEDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamChat(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer a21e_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"invalid api key","type":"invalid_request_error","code":"invalid_api_key"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-x\",\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var streamed []string
+	req := chatRequest{Model: defaultModel, Messages: []chatMessage{{Role: "user", Content: "hi"}}}
+	res, err := streamChat(context.Background(), srv.URL+"/v1", "a21e_key", req, func(s string) { streamed = append(streamed, s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "Hello" || res.Model != "gpt-x" || res.FinishReason != "stop" {
+		t.Fatalf("result = %+v", res)
+	}
+	if strings.Join(streamed, "|") != "Hel|lo" {
+		t.Fatalf("streamed = %q", streamed)
+	}
+
+	_, err = streamChat(context.Background(), srv.URL+"/v1", "wrong", req, func(string) {})
+	var ae *apiStatusError
+	if !errors.As(err, &ae) || ae.Status != 401 || ae.Message != "invalid api key" || ae.Code != "invalid_api_key" {
+		t.Fatalf("error = %#v", err)
+	}
+	if exitCodeFor(err) != exitAuth {
+		t.Fatalf("exit code = %d, want %d", exitCodeFor(err), exitAuth)
+	}
+}
+
+func TestStreamChatNonStreamingServer(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"a21e-auto","choices":[{"message":{"role":"assistant","content":"pong"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	var streamed string
+	res, err := streamChat(context.Background(), srv.URL, "k", chatRequest{Model: defaultModel}, func(s string) { streamed += s })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "pong" || streamed != "pong" {
+		t.Fatalf("result = %+v, streamed %q", res, streamed)
+	}
+}
@@ -0,0 +1,142 @@
+// output.go — Global output mode (--output json|text) and process exit codes.
+//
+// Human-readable progress goes to stderr in text mode. In JSON mode each command
+// writes exactly one JSON document to stdout (the result, or {"error": ...}) so
+// scripts never have to scrape prose.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+)
+
+// Exit codes. Scripts can rely on these staying stable.
+const (
+	exitOK         = 0
+	exitError      = 1 // unexpected or server-side failure
+	exitValidation = 2 // bad flags, unknown tool, missing input
+	exitAuth       = 3 // missing, invalid or unauthorized API key
+	exitNetwork    = 4 // could not reach the API
+	exitApply      = 5 // key created but applying tool configuration failed
+	exitDrift      = 6 // a21e verify found configuration that no longer matches
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var outputMode = outputText
+
+// codedError attaches an exit code to an error.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+func jsonOutput() bool {
+	return outputMode == outputJSON
+}
+
+// humanOut is where progress prose goes: stderr in text mode, nowhere in JSON mode.
+func humanOut() io.Writer {
+	if jsonOutput() {
+		return io.Discard
+	}
+	return os.Stderr
+}
+
+// exitCodeFor maps an error to the exit code contract above.
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	var ae *apiStatusError
+	if errors.As(err, &ae) {
+		switch {
+		case ae.Status == 401 || ae.Status == 403:
+			return exitAuth
+		case ae.Status >= 400 && ae.Status < 500:
+			return exitValidation
+		default:
+			return exitError
+		}
+	}
+	var ue *url.Error
+	var ne net.Error
+	if errors.As(err, &ue) || errors.As(err, &ne) {
+		return exitNetwork
+	}
+	return exitError
+}
+
+func exitKind(code int) string {
+	switch code {
+	case exitValidation:
+		return "validation"
+	case exitAuth:
+		return "auth"
+	case exitNetwork:
+		return "network"
+	case exitApply:
+		return "apply"
+	case exitDrift:
+		return "drift"
+	default:
+		return "error"
+	}
+}
+
+type errorDocument struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// exitWithError reports err for the given command in the current output mode and exits.
+func exitWithError(command string, err error) {
+	code := exitCodeFor(err)
+	if jsonOutput() {
+		writeJSON(errorDocument{Error: errorBody{Kind: exitKind(code), Message: err.Error(), ExitCode: code}})
+	} else if command == "" {
+		fmt.Fprintf(os.Stderr, "a21e: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "a21e %s: %v\n", command, err)
+	}
+	os.Exit(code)
+}
+
+func writeJSON(v any) {
+	_ = encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w in the same indented form as writeJSON.
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil is success", err: nil, want: exitOK},
+		{name: "unauthorized is auth", err: &apiStatusError{Status: 401}, want: exitAuth},
+		{name: "forbidden is auth", err: fmt.Errorf("wrapped: %w", &apiStatusError{Status: 403}), want: exitAuth},
+		{name: "bad request is validation", err: &apiStatusError{Status: 422}, want: exitValidation},
+		{name: "server error is generic", err: &apiStatusError{Status: 502}, want: exitError},
+		{name: "transport error is network", err: &url.Error{Op: "Get", URL: "https://api.a21e.com", Err: errors.New("dial tcp: refused")}, want: exitNetwork},
+		{name: "explicit code wins", err: withExitCode(exitApply, errors.New("boom")), want: exitApply},
+		{name: "plain error is generic", err: errors.New("boom"), want: exitError},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := exitCodeFor(tc.err); got != tc.want {
+				t.Fatalf("exitCodeFor(%v) = %d, want %d", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+// owner_other.go — file ownership is not carried over on non-Unix systems.
+
+package main
+
+import "os"
+
+func chownLike(f *os.File, like os.FileInfo) error { return nil }
@@ -0,0 +1,25 @@
+//go:build unix
+
+// owner_unix.go — preserving file ownership on Unix.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chownLike gives f the owner and group of like. Only root can give a file away, so
+// when we may not (EPERM) the file simply stays owned by the current user.
+func chownLike(f *os.File, like os.FileInfo) error {
+	st, ok := like.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return nil
+}
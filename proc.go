@@ -0,0 +1,86 @@
+// proc.go — Read the parent process chain from procfs (Linux).
+//
+// Detection uses this to see which program launched the shell a21e runs in.
+// The procfs root is a parameter so tests can point it at a fake tree.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxProcAncestors = 16
+
+type procInfo struct {
+	PID     int
+	PPID    int
+	Comm    string
+	Cmdline []string
+}
+
+// readProcInfo reads /proc/<pid>/stat and cmdline under root.
+func readProcInfo(root string, pid int) (*procInfo, error) {
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	// Format: "pid (comm) state ppid ..."; comm may itself contain spaces and parens.
+	open := bytes.IndexByte(stat, '(')
+	closing := bytes.LastIndexByte(stat, ')')
+	if open < 0 || closing < open {
+		return nil, fmt.Errorf("malformed %s/stat", dir)
+	}
+	fields := strings.Fields(string(stat[closing+1:]))
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("malformed %s/stat", dir)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s/stat: %w", dir, err)
+	}
+	info := &procInfo{PID: pid, PPID: ppid, Comm: string(stat[open+1 : closing])}
+
+	if raw, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		for _, arg := range bytes.Split(bytes.TrimRight(raw, "\x00"), []byte{0}) {
+			if len(arg) > 0 {
+				info.Cmdline = append(info.Cmdline, string(arg))
+			}
+		}
+	}
+	return info, nil
+}
+
+// procAncestors returns the parents of pid, nearest first, stopping at init, at an
+// unreadable entry, or after maxProcAncestors steps.
+func procAncestors(root string, pid int) []procInfo {
+	var chain []procInfo
+	self, err := readProcInfo(root, pid)
+	if err != nil {
+		return nil
+	}
+	next := self.PPID
+	for i := 0; i < maxProcAncestors && next > 1; i++ {
+		info, err := readProcInfo(root, next)
+		if err != nil {
+			break
+		}
+		chain = append(chain, *info)
+		next = info.PPID
+	}
+	return chain
+}
+
+// names returns the lowercase comm and executable base name, for matching.
+func (p procInfo) names() []string {
+	names := []string{strings.ToLower(p.Comm)}
+	if len(p.Cmdline) > 0 {
+		names = append(names, strings.ToLower(filepath.Base(p.Cmdline[0])))
+	}
+	return names
+}
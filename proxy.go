@@ -0,0 +1,209 @@
+// proxy.go — `a21e proxy`: a local OpenAI-compatible endpoint that injects the key.
+//
+// Tools that can only talk to localhost, or cannot send a21e's headers, point their
+// OpenAI base URL at http://127.0.0.1:<port>/v1 with any dummy key. The proxy replaces
+// the client's credentials with the stored a21e key and forwards to
+// openAIBaseURL(baseURL), flushing streamed (SSE) responses as they arrive.
+//
+// The listener is bound to loopback only. Requests whose Host or Origin is not a
+// loopback name are refused, so a web page cannot spend the key through DNS
+// rebinding or a cross-site request.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const defaultProxyPort = 8421
+
+// proxyLogEntry is one forwarded request. In JSON output mode entries are written to
+// stdout as JSON lines.
+type proxyLogEntry struct {
+	Time      string `json:"time"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newProxyFlagSet() (*flag.FlagSet, *int) {
+	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
+	return fs, fs.Int("port", defaultProxyPort, "Port to listen on (127.0.0.1 only)")
+}
+
+func runProxy(args []string) {
+	fs, port := newProxyFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+
+	apiKey := requireAPIKey("proxy")
+	upstream, err := url.Parse(openAIBaseURL(getAPIBaseURL()))
+	if err != nil {
+		exitWithError("proxy", withExitCode(exitValidation, fmt.Errorf("invalid API URL: %w", err)))
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		exitWithError("proxy", fmt.Errorf("could not listen on 127.0.0.1:%d: %w", *port, err))
+	}
+	local := "http://" + ln.Addr().String() + "/v1"
+	fmt.Fprintf(os.Stderr, "a21e proxy forwarding %s -> %s\n", local, upstream)
+	fmt.Fprintf(os.Stderr, "Point tools at OPENAI_BASE_URL=%s with any API key (e.g. OPENAI_API_KEY=a21e-proxy). Ctrl-C to stop.\n", local)
+
+	srv := &http.Server{
+		Handler:           newProxyHandler(upstream, apiKey, logProxyEntry),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		exitWithError("proxy", err)
+	}
+}
+
+// newProxyHandler forwards /v1/* to upstream (an OpenAI-compatible base URL ending
+// in its version path) with apiKey as the only credentials.
+func newProxyHandler(upstream *url.URL, apiKey string, logf func(proxyLogEntry)) http.Handler {
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			out := pr.Out
+			out.URL.Scheme = upstream.Scheme
+			out.URL.Host = upstream.Host
+			out.URL.Path = strings.TrimRight(upstream.Path, "/") + strings.TrimPrefix(pr.In.URL.Path, "/v1")
+			out.URL.RawPath = ""
+			out.Host = upstream.Host
+			out.Header.Del("Cookie")
+			setOpenAIAuth(out.Header, apiKey)
+		},
+		Transport:     httpClient().Transport,
+		FlushInterval: -1, // stream SSE chunks immediately
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if rec, ok := w.(*statusRecorder); ok {
+				rec.err = err
+			}
+			writeProxyError(w, http.StatusBadGateway, "a21e proxy could not reach the API: "+err.Error())
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		switch {
+		case !isLoopbackHost(r.Host):
+			writeProxyError(rec, http.StatusForbidden, "a21e proxy only accepts requests addressed to localhost")
+		case !allowedOrigin(r.Header.Get("Origin")):
+			writeProxyError(rec, http.StatusForbidden, "a21e proxy does not accept cross-origin browser requests")
+		case r.URL.Path != "/v1" && !strings.HasPrefix(r.URL.Path, "/v1/"):
+			writeProxyError(rec, http.StatusNotFound, "a21e proxy serves the OpenAI-compatible API under /v1")
+		default:
+			rp.ServeHTTP(rec, r)
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		entry := proxyLogEntry{
+			Time:      start.UTC().Format(time.RFC3339),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    status,
+			LatencyMS: time.Since(start).Milliseconds(),
+		}
+		if rec.err != nil {
+			entry.Error = rec.err.Error()
+		}
+		logf(entry)
+	})
+}
+
+// statusRecorder captures the response status. Unwrap lets the reverse proxy reach
+// the underlying writer's Flush for streamed responses.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	err    error // set when the upstream could not be reached
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func isLoopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// allowedOrigin accepts requests without an Origin (CLIs, editor backends) and
+// browser requests from local pages.
+func allowedOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && isLoopbackHost(u.Host)
+}
+
+// writeProxyError replies with an OpenAI-style error body so clients show the message.
+func writeProxyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"message": message, "type": "a21e_proxy_error"},
+	})
+}
+
+func logProxyEntry(e proxyLogEntry) {
+	if jsonOutput() {
+		_ = json.NewEncoder(os.Stdout).Encode(e)
+		return
+	}
+	line := fmt.Sprintf("%s %s %s -> %d in %dms", e.Time, e.Method, e.Path, e.Status, e.LatencyMS)
+	if e.Error != "" {
+		line += " (" + e.Error + ")"
+	}
+	fmt.Fprintln(os.Stderr, line)
+}
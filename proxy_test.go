@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProxyInjectsKeyAndStreams(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer a21e_stored_key" {
+			http.Error(w, "bad auth "+got, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/chat/completions" || r.URL.RawQuery != "stream=1" {
+			http.Error(w, "bad path "+r.URL.String(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release // the client must see the first event before the stream ends
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	base, _ := url.Parse(upstream.URL + "/api/v1")
+	var mu sync.Mutex
+	var logged []proxyLogEntry
+	proxy := httptest.NewServer(newProxyHandler(base, "a21e_stored_key", func(e proxyLogEntry) {
+		mu.Lock()
+		logged = append(logged, e)
+		mu.Unlock()
+	}))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, proxy.URL+"/v1/chat/completions?stream=1", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer dummy")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("first streamed line = %q, %v", line, err)
+	}
+	release <- struct{}{}
+	resp.Body.Close()
+	proxy.Close() // waits for the handler, so the request has been logged
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logged) != 1 || logged[0].Status != http.StatusOK || logged[0].Path != "/v1/chat/completions" {
+		t.Fatalf("log entries = %+v", logged)
+	}
+}
+
+func TestProxyRejects(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request reached upstream: %s", r.URL)
+	}))
+	defer upstream.Close()
+	base, _ := url.Parse(upstream.URL + "/v1")
+	proxy := httptest.NewServer(newProxyHandler(base, "k", func(proxyLogEntry) {}))
+	defer proxy.Close()
+
+	testCases := []struct {
+		name   string
+		path   string
+		host   string
+		origin string
+		want   int
+	}{
+		{name: "outside /v1", path: "/admin", want: http.StatusNotFound},
+		{name: "rebinding host", path: "/v1/models", host: "evil.example:8421", want: http.StatusForbidden},
+		{name: "cross-origin page", path: "/v1/models", origin: "https://evil.example", want: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, proxy.URL+tc.path, nil)
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
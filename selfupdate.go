@@ -0,0 +1,456 @@
+// selfupdate.go — `a21e self-update`: replace this binary with the latest release.
+//
+// Each channel publishes <release_url>/<channel>/latest.json:
+//
+//	{
+//	  "version": "1.4.0",
+//	  "notes_url": "https://github.com/a21e/cli/releases/tag/v1.4.0",
+//	  "assets": [
+//	    {"os": "linux", "arch": "amd64", "url": "a21e-linux-amd64",
+//	     "sha256": "<hex>", "signature_url": "a21e-linux-amd64.sig"}
+//	  ]
+//	}
+//
+// URLs may be relative to latest.json. latest.json itself is not trusted: each
+// asset's detached signature (base64 ed25519, see releaseSignedPayload) covers the
+// version, os, arch and sha256, so a tampered manifest cannot pass an older signed
+// build off as a newer one. A download is installed only if it matches sha256, the
+// signature verifies against releaseSigningKey, and the signed version is newer
+// than the running one. The new binary is written next to the running one and
+// renamed over it.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// releasePublicKey is the base64 ed25519 public key a21e releases are signed with.
+const releasePublicKey = "ColhYLAZnAew4gT2KkhyxjbCeXQzF8yO9rnwBHP206E="
+
+// releaseSigningKey is the key self-update verifies against. Builds that publish
+// their own releases can override it with -ldflags "-X main.releaseSigningKey=...".
+var releaseSigningKey = releasePublicKey
+
+var updateChannels = []string{"stable", "beta"}
+
+// maxArtifactSize bounds downloads; the binary is a few MB.
+const maxArtifactSize = 256 << 20
+
+type releaseAsset struct {
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	URL          string `json:"url"`
+	SHA256       string `json:"sha256"`
+	SignatureURL string `json:"signature_url"`
+}
+
+type releaseInfo struct {
+	Version  string         `json:"version"`
+	NotesURL string         `json:"notes_url,omitempty"`
+	Assets   []releaseAsset `json:"assets"`
+
+	manifestURL *url.URL // where latest.json was read; relative URLs resolve against it
+}
+
+// updateResult is the self-update --output json document.
+type updateResult struct {
+	Current         string `json:"current_version"`
+	Latest          string `json:"latest_version"`
+	Channel         string `json:"channel"`
+	UpdateAvailable bool   `json:"update_available"`
+	Updated         bool   `json:"updated"`
+	Path            string `json:"path,omitempty"`
+	NotesURL        string `json:"notes_url,omitempty"`
+}
+
+// updateOptions holds everything selfUpdate depends on, so tests can point it at a
+// local release server and a temporary "executable".
+type updateOptions struct {
+	releaseURL string
+	channel    string
+	current    string
+	exePath    string
+	publicKey  string
+	goos       string
+	goarch     string
+	checkOnly  bool
+}
+
+// selfUpdateFlags are the options of a21e self-update.
+type selfUpdateFlags struct {
+	check   *bool
+	channel *string
+	asJSON  *bool
+}
+
+func newSelfUpdateFlagSet() (*flag.FlagSet, selfUpdateFlags) {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	return fs, selfUpdateFlags{
+		check:   fs.Bool("check", false, "Only report whether a newer release is available"),
+		channel: fs.String("channel", settingValue("update_channel"), "Release channel: "+strings.Join(updateChannels, " or ")),
+		asJSON:  fs.Bool("json", false, "Print the result as JSON (same as --output json)"),
+	}
+}
+
+func runSelfUpdate(args []string) {
+	fs, f := newSelfUpdateFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *f.asJSON {
+		outputMode = outputJSON
+	}
+	if err := validateUpdateChannel(*f.channel); err != nil {
+		exitWithError("self-update", withExitCode(exitValidation, err))
+	}
+
+	opts := updateOptions{
+		releaseURL: settingValue("release_url"),
+		channel:    *f.channel,
+		current:    version,
+		publicKey:  releaseSigningKey,
+		goos:       runtime.GOOS,
+		goarch:     runtime.GOARCH,
+		checkOnly:  *f.check,
+	}
+	if !*f.check {
+		exe, err := os.Executable()
+		if err != nil {
+			exitWithError("self-update", fmt.Errorf("could not locate the running binary: %w", err))
+		}
+		opts.exePath = exe
+	}
+	res, err := selfUpdate(opts)
+	if err != nil {
+		exitWithError("self-update", err)
+	}
+
+	if jsonOutput() {
+		writeJSON(res)
+		return
+	}
+	switch {
+	case !res.UpdateAvailable:
+		fmt.Fprintf(os.Stderr, "a21e %s is the latest %s release.\n", res.Current, res.Channel)
+	case res.Updated:
+		fmt.Fprintf(os.Stderr, "Updated %s from %s to %s.\n", res.Path, res.Current, res.Latest)
+	default:
+		fmt.Fprintf(os.Stderr, "a21e %s is available on the %s channel (you have %s). Run a21e self-update to install it.\n", res.Latest, res.Channel, res.Current)
+	}
+	if res.UpdateAvailable && res.NotesURL != "" {
+		fmt.Fprintln(os.Stderr, "Release notes:", res.NotesURL)
+	}
+}
+
+func validateUpdateChannel(v string) error {
+	for _, c := range updateChannels {
+		if c == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid channel %q (want %s)", v, strings.Join(updateChannels, " or "))
+}
+
+func selfUpdate(opts updateOptions) (*updateResult, error) {
+	rel, err := fetchLatestRelease(opts.releaseURL, opts.channel)
+	if err != nil {
+		return nil, err
+	}
+	res := &updateResult{
+		Current:         opts.current,
+		Latest:          rel.Version,
+		Channel:         opts.channel,
+		UpdateAvailable: compareVersions(rel.Version, opts.current) > 0,
+		NotesURL:        rel.NotesURL,
+	}
+	if opts.checkOnly || !res.UpdateAvailable {
+		return res, nil
+	}
+	// rel.Version is only a claim until downloadVerified has checked it against
+	// the signature; the comparison above is repeated on the signed value there.
+
+	key, err := parseSigningKey(opts.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := rel.assetFor(opts.goos, opts.goarch)
+	if err != nil {
+		return nil, err
+	}
+	data, err := downloadVerified(rel, asset, key, opts.current)
+	if err != nil {
+		return nil, err
+	}
+	path, err := replaceExecutable(opts.exePath, data)
+	if err != nil {
+		return nil, err
+	}
+	res.Updated = true
+	res.Path = path
+	return res, nil
+}
+
+// fetchLatestRelease reads latest.json for channel.
+func fetchLatestRelease(releaseURL, channel string) (*releaseInfo, error) {
+	manifestURL, err := url.Parse(strings.TrimRight(releaseURL, "/") + "/" + channel + "/latest.json")
+	if err != nil {
+		return nil, withExitCode(exitValidation, fmt.Errorf("invalid release_url %q: %w", releaseURL, err))
+	}
+	raw, err := fetchReleaseFile(manifestURL.String(), 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	var rel releaseInfo
+	if err := json.Unmarshal(raw, &rel); err != nil {
+		return nil, fmt.Errorf("invalid release manifest %s: %w", manifestURL, err)
+	}
+	if rel.Version == "" {
+		return nil, fmt.Errorf("release manifest %s has no version", manifestURL)
+	}
+	rel.manifestURL = manifestURL
+	return &rel, nil
+}
+
+func fetchReleaseFile(rawURL string, limit int64) ([]byte, error) {
+	resp, err := httpClient().Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", rawURL, err)
+	}
+	if int64(len(raw)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, limit)
+	}
+	return raw, nil
+}
+
+func (r *releaseInfo) assetFor(goos, goarch string) (*releaseAsset, error) {
+	for i := range r.Assets {
+		if r.Assets[i].OS == goos && r.Assets[i].Arch == goarch {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no build for %s/%s", r.Version, goos, goarch)
+}
+
+func (r *releaseInfo) resolve(ref string) (string, error) {
+	u, err := r.manifestURL.Parse(ref)
+	if err != nil || ref == "" {
+		return "", fmt.Errorf("release %s has an invalid URL %q", r.Version, ref)
+	}
+	return u.String(), nil
+}
+
+func parseSigningKey(encoded string) (ed25519.PublicKey, error) {
+	if encoded == "" {
+		return nil, errors.New("this build of a21e has no release signing key, so it cannot verify updates; reinstall with the install script instead")
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, errors.New("this build of a21e has a malformed release signing key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// releaseSignedPayload is the message a release asset's signature covers. Binding
+// the version and platform to the checksum stops a signed build from being
+// installed under another version or on another platform.
+func releaseSignedPayload(version, goos, goarch, sha256Hex string) []byte {
+	return []byte(fmt.Sprintf("a21e-release\nversion=%s\nos=%s\narch=%s\nsha256=%s\n", version, goos, goarch, strings.ToLower(sha256Hex)))
+}
+
+// downloadVerified downloads asset and returns it only if its checksum and its
+// signature check out and the signed version is newer than current.
+func downloadVerified(rel *releaseInfo, asset *releaseAsset, key ed25519.PublicKey, current string) ([]byte, error) {
+	want, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("release %s lists an invalid sha256 for %s/%s", rel.Version, asset.OS, asset.Arch)
+	}
+	artifactURL, err := rel.resolve(asset.URL)
+	if err != nil {
+		return nil, err
+	}
+	sigURL, err := rel.resolve(asset.SignatureURL)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fetchReleaseFile(artifactURL, maxArtifactSize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], want) {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %x, want %x; not installing", artifactURL, sum, want)
+	}
+
+	rawSig, err := fetchReleaseFile(sigURL, 4<<10)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(rawSig)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature file %s; not installing", sigURL)
+	}
+	if !ed25519.Verify(key, releaseSignedPayload(rel.Version, asset.OS, asset.Arch, asset.SHA256), sig) {
+		return nil, fmt.Errorf("signature check failed for %s %s/%s (%s); not installing", rel.Version, asset.OS, asset.Arch, artifactURL)
+	}
+	if compareVersions(rel.Version, current) <= 0 {
+		return nil, fmt.Errorf("release %s is not newer than the installed %s; not installing", rel.Version, current)
+	}
+	return data, nil
+}
+
+// replaceExecutable atomically replaces the binary at exePath (following symlinks,
+// as package managers install them) with data, keeping its mode and owner. It
+// returns the path that was replaced.
+func replaceExecutable(exePath string, data []byte) (string, error) {
+	path, err := filepath.EvalSymlinks(exePath)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", exePath, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	movedAside := ""
+	if runtime.GOOS == "windows" {
+		// A running .exe cannot be replaced, but it can be renamed out of the way.
+		movedAside = path + ".old"
+		_ = os.Remove(movedAside)
+		if err := os.Rename(path, movedAside); err != nil {
+			return "", fmt.Errorf("could not move %s aside: %w", path, err)
+		}
+	}
+	if err := writeFileAtomic(path, data, info.Mode().Perm(), info); err != nil {
+		if movedAside != "" {
+			if rerr := os.Rename(movedAside, path); rerr != nil {
+				err = fmt.Errorf("%w; the previous binary is at %s (could not restore it: %v)", err, movedAside, rerr)
+			}
+		}
+		if errors.Is(err, os.ErrPermission) {
+			return "", fmt.Errorf("%w (re-run with sudo, or reinstall to a directory you can write)", err)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// compareVersions compares two semantic versions ("v" prefix optional) and returns
+// -1, 0 or 1. Pre-releases sort before their release; a non-release build such as
+// "dev" sorts before everything.
+func compareVersions(a, b string) int {
+	pa, oka := parseVersion(a)
+	pb, okb := parseVersion(b)
+	switch {
+	case !oka && !okb:
+		return 0
+	case !oka:
+		return -1
+	case !okb:
+		return 1
+	}
+	for i := 0; i < 3; i++ {
+		if pa.nums[i] != pb.nums[i] {
+			if pa.nums[i] < pb.nums[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePrerelease(pa.pre, pb.pre)
+}
+
+type semver struct {
+	nums [3]int
+	pre  string
+}
+
+func parseVersion(v string) (semver, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, pre, _ := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) < 1 || len(parts) > 3 {
+		return semver{}, false
+	}
+	var s semver
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		s.nums[i] = n
+	}
+	s.pre = pre
+	return s, true
+}
+
+// comparePrerelease orders pre-release tags per semver: none beats any, numeric
+// identifiers compare numerically and sort before alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves one release of body per channel, signed with priv.
+func releaseServer(t *testing.T, priv ed25519.PrivateKey, versions map[string]string, body []byte, tamper func(path string, b []byte) []byte) *httptest.Server {
+	t.Helper()
+	sum := sha256.Sum256(body)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		v, ok := "", false
+		if len(parts) == 3 {
+			v, ok = versions[parts[1]]
+		}
+		var out []byte
+		switch {
+		case ok && parts[2] == "latest.json":
+			out, _ = json.Marshal(releaseInfo{Version: v, Assets: []releaseAsset{
+				{OS: "linux", Arch: "amd64", URL: "../files/a21e-linux-amd64", SHA256: hex.EncodeToString(sum[:]), SignatureURL: "a21e-linux-amd64.sig"},
+			}})
+		case ok && parts[2] == "a21e-linux-amd64.sig":
+			sig := ed25519.Sign(priv, releaseSignedPayload(v, "linux", "amd64", hex.EncodeToString(sum[:])))
+			out = []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+		case r.URL.Path == "/releases/files/a21e-linux-amd64":
+			out = body
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if tamper != nil {
+			out = tamper(r.URL.Path, out)
+		}
+		w.Write(out)
+	}))
+}
+
+func TestSelfUpdate(t *testing.T) {
+	t.Parallel()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	newBinary := []byte("#!/bin/sh\necho a21e 1.5.0\n")
+	versions := map[string]string{"stable": "1.4.0", "beta": "v1.5.0-beta.2"}
+
+	cases := []struct {
+		name        string
+		signer      ed25519.PrivateKey
+		tamper      func(string, []byte) []byte
+		channel     string
+		current     string
+		checkOnly   bool
+		wantErr     string
+		wantLatest  string
+		wantUpdated bool
+	}{
+		{name: "installs stable", channel: "stable", current: "1.3.2", wantLatest: "1.4.0", wantUpdated: true},
+		{name: "installs beta", channel: "beta", current: "1.4.0", wantLatest: "v1.5.0-beta.2", wantUpdated: true},
+		{name: "dev build updates", channel: "stable", current: "dev", wantLatest: "1.4.0", wantUpdated: true},
+		{name: "up to date", channel: "stable", current: "v1.4.0", wantLatest: "1.4.0"},
+		{name: "check only", channel: "stable", current: "1.3.2", checkOnly: true, wantLatest: "1.4.0"},
+		{name: "bad checksum", channel: "stable", current: "1.3.2", wantErr: "checksum mismatch",
+			tamper: func(path string, b []byte) []byte {
+				if strings.HasSuffix(path, "amd64") {
+					return append(b, '#')
+				}
+				return b
+			}},
+		{name: "wrong signer", channel: "stable", current: "1.3.2", signer: otherPriv, wantErr: "signature check failed"},
+		{name: "relabelled version", channel: "stable", current: "1.3.2", wantErr: "signature check failed",
+			tamper: func(path string, b []byte) []byte {
+				if strings.HasSuffix(path, "latest.json") {
+					return []byte(strings.Replace(string(b), `"1.4.0"`, `"9.9.9"`, 1))
+				}
+				return b
+			}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			signer := priv
+			if c.signer != nil {
+				signer = c.signer
+			}
+			srv := releaseServer(t, signer, versions, newBinary, c.tamper)
+			defer srv.Close()
+
+			dir := t.TempDir()
+			exe := filepath.Join(dir, "a21e")
+			if err := os.WriteFile(exe, []byte("old binary"), 0o751); err != nil {
+				t.Fatal(err)
+			}
+			link := filepath.Join(dir, "a21e-link")
+			if err := os.Symlink(exe, link); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := selfUpdate(updateOptions{
+				releaseURL: srv.URL + "/releases",
+				channel:    c.channel,
+				current:    c.current,
+				exePath:    link,
+				publicKey:  base64.StdEncoding.EncodeToString(pub),
+				goos:       "linux",
+				goarch:     "amd64",
+				checkOnly:  c.checkOnly,
+			})
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %q", err, c.wantErr)
+				}
+				assertFile(t, exe, "old binary")
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Latest != c.wantLatest || res.Updated != c.wantUpdated {
+				t.Fatalf("result = %+v", res)
+			}
+			if !c.wantUpdated {
+				assertFile(t, exe, "old binary")
+				return
+			}
+			if res.Path != exe {
+				t.Fatalf("replaced %s, want the symlink target %s", res.Path, exe)
+			}
+			assertFile(t, exe, string(newBinary))
+			fi, err := os.Stat(exe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != 0o751 {
+				t.Fatalf("mode after update = %v, want 0751", fi.Mode().Perm())
+			}
+			if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+				t.Fatalf("symlink was replaced: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadVerifiedRefusesOlderRelease(t *testing.T) {
+	t.Parallel()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	srv := releaseServer(t, priv, map[string]string{"stable": "1.4.0"}, []byte("new"), nil)
+	defer srv.Close()
+	rel, err := fetchLatestRelease(srv.URL+"/releases", "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, _ := rel.assetFor("linux", "amd64")
+	if _, err := downloadVerified(rel, asset, pub, "1.5.0"); err == nil || !strings.Contains(err.Error(), "not newer") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := downloadVerified(rel, asset, pub, "1.3.0"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelfUpdateNeedsSigningKey(t *testing.T) {
+	t.Parallel()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	srv := releaseServer(t, priv, map[string]string{"stable": "2.0.0"}, []byte("new"), nil)
+	defer srv.Close()
+	_, err := selfUpdate(updateOptions{releaseURL: srv.URL + "/releases", channel: "stable", current: "1.0.0", goos: "linux", goarch: "amd64"})
+	if err == nil || !strings.Contains(err.Error(), "no release signing key") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := parseSigningKey(releaseSigningKey); err != nil {
+		t.Fatalf("built-in release key: %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.4.0", "1.3.9", 1},
+		{"v1.4.0", "1.4.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.4", "1.4.0", 0},
+		{"1.5.0-beta.2", "1.5.0-beta.10", -1},
+		{"1.5.0-beta.2", "1.5.0", -1},
+		{"1.5.0-alpha", "1.5.0-beta", -1},
+		{"1.5.0-rc.1", "1.4.9", 1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"dev", "0.0.1", -1},
+		{"0.0.1", "dev", 1},
+	}
+	for _, c := range cases {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// state.go — ~/.a21e/state.json: which keys this CLI created and where it applied them.
+//
+// The state file never contains key material, only IDs and prefixes. Every update
+// takes an exclusive lock file (state.json.lock, created with O_EXCL so it works the
+// same on every OS) and replaces state.json atomically, so parallel invocations
+// neither corrupt it nor lose each other's records.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const stateVersion = 1
+
+// stateLockTimeout bounds how long an update waits for another a21e process.
+// A lock older than stateLockStale is assumed to belong to a crashed process.
+const stateLockTimeout = 10 * time.Second
+const stateLockStale = 2 * time.Minute
+
+type cliState struct {
+	Version int          `json:"version"`
+	Keys    []stateKey   `json:"keys"`
+	Applies []stateApply `json:"applies"`
+}
+
+// stateKey is a key created by this CLI.
+type stateKey struct {
+	ID        string `json:"id"`
+	Prefix    string `json:"prefix"`
+	Tool      string `json:"tool"`
+	Workspace string `json:"workspace"`
+	Scope     string `json:"scope"`
+	CreatedAt string `json:"created_at"`
+}
+
+// stateApply is a file this CLI wrote a key into. Markers are managed block markers,
+// Settings are JSON setting names; together they say exactly what to undo.
+type stateApply struct {
+	KeyID      string   `json:"key_id"`
+	Tool       string   `json:"tool"`
+	Path       string   `json:"path"`
+	BackupPath string   `json:"backup_path,omitempty"`
+	Markers    []string `json:"markers,omitempty"`
+	Settings   []string `json:"settings,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	AppliedAt  string   `json:"applied_at"`
+}
+
+func stateFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".a21e", "state.json"), nil
+}
+
+// loadState reads the state file without locking; a missing file is an empty state.
+func loadState() (*cliState, error) {
+	path, err := stateFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return readStateFile(path)
+}
+
+func readStateFile(path string) (*cliState, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cliState{Version: stateVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read state file: %w", err)
+	}
+	var st cliState
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return nil, fmt.Errorf("state file %s is invalid: %w", path, err)
+	}
+	if st.Version > stateVersion {
+		return nil, fmt.Errorf("state file %s was written by a newer a21e (version %d)", path, st.Version)
+	}
+	st.Version = stateVersion
+	return &st, nil
+}
+
+// updateState applies fn to the state under the lock and saves the result.
+func updateState(fn func(*cliState) error) error {
+	path, err := stateFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+	unlock, err := acquireStateLock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := readStateFile(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(st); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(raw, '\n'), 0o600, nil)
+}
+
+// acquireStateLock creates lockPath exclusively, waiting for other holders.
+func acquireStateLock(lockPath string) (func(), error) {
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, _ = f.WriteString(strconv.Itoa(os.Getpid()))
+			f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("could not lock state file: %w", err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > stateLockStale {
+			if breakStaleLock(lockPath) {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for state lock %s (remove it if no other a21e is running)", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// breakStaleLock removes lockPath if it is still stale and reports whether the
+// caller should retry at once. Waiters that find the same stale lock take turns
+// through a guard file and re-check under it, so a fresh lock created by the first
+// of them is never removed by the next.
+func breakStaleLock(lockPath string) bool {
+	guard := lockPath + ".break"
+	f, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		// The guard is held only for a stat and a remove, so an old one is left
+		// over from a crash.
+		if info, statErr := os.Stat(guard); statErr == nil && time.Since(info.ModTime()) > stateLockStale {
+			_ = os.Remove(guard)
+		}
+		return false
+	}
+	f.Close()
+	defer os.Remove(guard)
+
+	info, err := os.Stat(lockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil || time.Since(info.ModTime()) <= stateLockStale {
+		return false
+	}
+	return os.Remove(lockPath) == nil
+}
+
+// recordKey remembers a key this CLI created.
+func recordKey(resp *createCliKeyResp, toolID, workspace, scope string) error {
+	return updateState(func(st *cliState) error {
+		st.Keys = append(st.Keys, stateKey{
+			ID:        resp.ID,
+			Prefix:    keyPrefixFromRaw(resp.Key),
+			Tool:      toolID,
+			Workspace: workspace,
+			Scope:     scope,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		})
+		return nil
+	})
+}
+
+// recordApply remembers that keyID was written to summary.UpdatedPath. An earlier
+// record for the same tool and path is replaced, so the state lists each target once.
+func recordApply(keyID, toolID string, summary *applySummary) error {
+	return updateState(func(st *cliState) error {
+		rec := stateApply{
+			KeyID:      keyID,
+			Tool:       toolID,
+			Path:       summary.UpdatedPath,
+			BackupPath: summary.BackupPath,
+			Markers:    summary.Markers,
+			Settings:   summary.Settings,
+			Model:      summary.Model,
+			AppliedAt:  time.Now().UTC().Format(time.RFC3339),
+		}
+		for i, a := range st.Applies {
+			if a.Tool == toolID && a.Path == rec.Path {
+				if rec.BackupPath == "" {
+					rec.BackupPath = a.BackupPath
+				}
+				st.Applies[i] = rec
+				return nil
+			}
+		}
+		st.Applies = append(st.Applies, rec)
+		return nil
+	})
+}
+
+// keyByID returns the record for a key this CLI created, or nil.
+func (st *cliState) keyByID(id string) *stateKey {
+	for i := range st.Keys {
+		if st.Keys[i].ID == id {
+			return &st.Keys[i]
+		}
+	}
+	return nil
+}
+
+// applyFor returns the apply record for toolID's target path, or nil.
+func (st *cliState) applyFor(toolID, path string) *stateApply {
+	for i := range st.Applies {
+		if st.Applies[i].Tool == toolID && st.Applies[i].Path == path {
+			return &st.Applies[i]
+		}
+	}
+	return nil
+}
+
+// recordExport remembers that keyID was exported to a managed block in path.
+// GitHub Actions env files are per-job and are not recorded. Relative paths are
+// stored absolute so rotate and uninstall find the file from any directory.
+func recordExport(keyID, toolID, format, path string) error {
+	if format == "github-actions" || path == "-" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("could not resolve %s: %w", path, err)
+	}
+	return recordApply(keyID, toolID, &applySummary{
+		UpdatedPath: abs,
+		Markers:     []string{exportBlockStart, exportBlockEnd},
+	})
+}
+
+// warnState reports a failed state update. The state file is bookkeeping, so a
+// failure never fails the command that created or applied the key.
+func warnState(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "a21e: could not update ~/.a21e/state.json: %v\n", err)
+	}
+}
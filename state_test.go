@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateStateConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp := &createCliKeyResp{ID: fmt.Sprintf("key_%d", i), Key: fmt.Sprintf("a21e_%012d_secret", i)}
+			errs <- recordKey(resp, "cursor", "ws_1", "user")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Keys) != n {
+		t.Fatalf("got %d keys, want %d (updates were lost)", len(st.Keys), n)
+	}
+	path, _ := stateFilePath()
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("state file mode = %o, want 600", perm)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".state.json.tmp-*")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestRecordApplyReplacesTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	first := &applySummary{UpdatedPath: "/home/u/.zshrc", BackupPath: "/home/u/.zshrc.bak", Markers: []string{"# >>> a", "# <<< a"}}
+	if err := recordApply("key_1", "codex_cli", first); err != nil {
+		t.Fatal(err)
+	}
+	second := &applySummary{UpdatedPath: "/home/u/.zshrc", Markers: first.Markers}
+	if err := recordApply("key_2", "codex_cli", second); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordExport("key_2", "codex_cli", "dotenv", ".env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordExport("key_2", "codex_cli", "github-actions", "/tmp/github_env"); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Applies) != 2 {
+		t.Fatalf("got %d applies, want 2: %+v", len(st.Applies), st.Applies)
+	}
+	got := st.Applies[0]
+	if got.KeyID != "key_2" || got.BackupPath != "/home/u/.zshrc.bak" {
+		t.Errorf("apply record = %+v, want key_2 keeping the original backup", got)
+	}
+	if wantPath, _ := filepath.Abs(".env"); st.Applies[1].Path != wantPath || len(st.Applies[1].Markers) != 2 {
+		t.Errorf("export record = %+v", st.Applies[1])
+	}
+}
+
+func TestStaleStateLockIsBroken(t *testing.T) {
+	dir := t.TempDir()
+	lock := filepath.Join(dir, "state.json.lock")
+	if err := os.WriteFile(lock, []byte("99999"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * stateLockStale)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := acquireStateLock(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	// A waiter that saw the stale lock before another waiter broke it and took a
+	// fresh one must leave the fresh lock alone.
+	unlock, err = acquireStateLock(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if breakStaleLock(lock) {
+		t.Fatal("breakStaleLock asked for a retry on a fresh lock")
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Fatalf("fresh lock was removed: %v", err)
+	}
+	if _, err := os.Stat(lock + ".break"); !os.IsNotExist(err) {
+		t.Errorf("break guard left behind: %v", err)
+	}
+}
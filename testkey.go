@@ -0,0 +1,206 @@
+// testkey.go — `a21e test-key`: does each configured tool's key actually work?
+//
+// For every applied target (and every export file state.json knows about) the key is
+// read back from the file itself, not from the credentials file, and used for a
+// one-token chat completion against openAIBaseURL.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+const testKeyTimeout = 30 * time.Second
+
+// testKeyResult is the outcome for one configured target.
+type testKeyResult struct {
+	Tool      string `json:"tool"`
+	Path      string `json:"path"`
+	Key       string `json:"key_masked,omitempty"`
+	Status    string `json:"status"` // ok, failed or missing
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Model     string `json:"model,omitempty"` // the model that answered
+	ErrorKind string `json:"error_kind,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// testKeyTarget is a file holding a tool's key.
+type testKeyTarget struct {
+	tool  string
+	path  string
+	model string
+	read  func() (string, error)
+}
+
+func newTestKeyFlagSet() (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("test-key", flag.ContinueOnError)
+	return fs, fs.String("tool", "", "Only test this tool")
+}
+
+func runTestKey(args []string) {
+	fs, only := newTestKeyFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *only != "" && !isValidToolID(*only) {
+		exitWithError("test-key", withExitCode(exitValidation, fmt.Errorf("invalid tool_id %q", *only)))
+	}
+
+	st, err := loadState()
+	if err != nil {
+		exitWithError("test-key", err)
+	}
+	base := openAIBaseURL(getAPIBaseURL())
+	results := []testKeyResult{}
+	exitCode := exitOK
+	for _, target := range testKeyTargets(st) {
+		if *only != "" && target.tool != *only {
+			continue
+		}
+		res := testTarget(base, target)
+		if res.Status != "ok" && exitCode == exitOK {
+			exitCode = testKeyExitCode(res.ErrorKind)
+		}
+		results = append(results, res)
+	}
+
+	if jsonOutput() {
+		writeJSON(results)
+	} else {
+		printTestKeyResults(results)
+	}
+	os.Exit(exitCode)
+}
+
+// testKeyTargets lists applied tool targets, then export files recorded in state.
+func testKeyTargets(st *cliState) []testKeyTarget {
+	var targets []testKeyTarget
+	seen := map[string]bool{}
+	for _, tool := range appliedTools(st) {
+		path, _ := tool.Target()
+		seen[path] = true
+		targets = append(targets, testKeyTarget{
+			tool:  tool.ID(),
+			path:  path,
+			model: appliedModel(st.applyFor(tool.ID(), path)),
+			read:  tool.CurrentKey,
+		})
+	}
+	for _, a := range st.Applies {
+		if seen[a.Path] || len(a.Markers) != 2 || a.Markers[0] != exportBlockStart {
+			continue
+		}
+		seen[a.Path] = true
+		path := a.Path
+		targets = append(targets, testKeyTarget{
+			tool:  a.Tool,
+			path:  path,
+			model: appliedModel(&a),
+			read: func() (string, error) {
+				key, _, err := readExportedValues("dotenv", path)
+				return key, err
+			},
+		})
+	}
+	return targets
+}
+
+// appliedModel is the model a21e wrote into a target, defaulting for older records.
+func appliedModel(rec *stateApply) string {
+	if rec != nil && rec.Model != "" {
+		return rec.Model
+	}
+	return defaultModel
+}
+
+func testTarget(openAIBase string, target testKeyTarget) testKeyResult {
+	res := testKeyResult{Tool: target.tool, Path: target.path}
+	key, err := target.read()
+	if err != nil {
+		res.Status, res.ErrorKind, res.Error = "failed", "config", err.Error()
+		return res
+	}
+	if key == "" {
+		res.Status, res.ErrorKind, res.Error = "missing", "config", "no a21e key found in this file"
+		return res
+	}
+	res.Key = maskKey(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testKeyTimeout)
+	defer cancel()
+	start := time.Now()
+	req := chatRequest{Model: target.model, Messages: []chatMessage{{Role: "user", Content: "ping"}}, MaxTokens: 1}
+	result, err := streamChat(ctx, openAIBase, key, req, func(string) {})
+	res.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		res.Status, res.ErrorKind, res.Error = "failed", testKeyErrorKind(err), err.Error()
+		return res
+	}
+	res.Status, res.Model = "ok", result.Model
+	return res
+}
+
+// testKeyErrorKind names what went wrong in terms a user can act on.
+func testKeyErrorKind(err error) string {
+	var ae *apiStatusError
+	if errors.As(err, &ae) {
+		switch {
+		case ae.Status == 401 || ae.Status == 403:
+			return "auth"
+		case ae.Status == 402 || ae.Status == 429:
+			return "quota"
+		case ae.Status == 404:
+			return "model"
+		case ae.Status >= 500:
+			return "server"
+		}
+		return "request"
+	}
+	var ue *url.Error
+	var ne net.Error
+	if errors.As(err, &ue) || errors.As(err, &ne) || errors.Is(err, context.DeadlineExceeded) {
+		return "network"
+	}
+	return "error"
+}
+
+func testKeyExitCode(kind string) int {
+	switch kind {
+	case "auth":
+		return exitAuth
+	case "network":
+		return exitNetwork
+	case "config":
+		return exitApply
+	default:
+		return exitError
+	}
+}
+
+func printTestKeyResults(results []testKeyResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No applied configuration found. Run a21e init --apply first.")
+		return
+	}
+	failed := 0
+	for _, r := range results {
+		switch r.Status {
+		case "ok":
+			fmt.Fprintf(os.Stderr, "  %-18s ok in %dms, answered by %s (%s)\n", r.Tool, r.LatencyMS, r.Model, r.Path)
+		default:
+			failed++
+			fmt.Fprintf(os.Stderr, "  %-18s %s [%s]: %s (%s)\n", r.Tool, r.Status, r.ErrorKind, r.Error, r.Path)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d keys work.\n", len(results)-failed, len(results))
+}
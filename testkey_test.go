@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestKeyTargets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/zsh")
+
+	keys := &fakeKeyServer{}
+	mux := http.NewServeMux()
+	mux.Handle("/", keys)
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch {
+		case strings.Contains(auth, "_cursor_"):
+			fmt.Fprint(w, `{"model":"a21e-fast","choices":[{"message":{"role":"assistant","content":"p"},"finish_reason":"length"}]}`)
+		case strings.Contains(auth, "_openai_cli_custom_"):
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"key revoked"}}`)
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"monthly quota exceeded"}}`)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	exportPath := filepath.Join(home, ".env.claude")
+	settings := initSettings{apiKey: "admin", baseURL: srv.URL, workspace: "ws_1", scope: "user", model: defaultModel, apply: true}
+	for _, id := range []string{"cursor", "openai_cli_custom"} {
+		if _, _, err := setupTool(settings, id, false, io.Discard); err != nil {
+			t.Fatalf("setupTool(%s): %v", id, err)
+		}
+	}
+	exportSettings := settings
+	exportSettings.apply, exportSettings.exportFormat, exportSettings.exportPath = false, "dotenv", exportPath
+	if _, _, err := setupTool(exportSettings, "claude_code_cli", false, io.Discard); err != nil {
+		t.Fatalf("setupTool(claude_code_cli): %v", err)
+	}
+
+	st, err := loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]testKeyResult{}
+	for _, target := range testKeyTargets(st) {
+		got[target.tool] = testTarget(openAIBaseURL(srv.URL), target)
+	}
+
+	want := map[string]struct{ status, kind, model string }{
+		"cursor":            {status: "ok", model: "a21e-fast"},
+		"openai_cli_custom": {status: "failed", kind: "auth"},
+		"claude_code_cli":   {status: "failed", kind: "quota"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("tested %d targets, want %d: %+v", len(got), len(want), got)
+	}
+	for id, w := range want {
+		r := got[id]
+		if r.Status != w.status || r.ErrorKind != w.kind || r.Model != w.model {
+			t.Errorf("%s: %+v, want status %q kind %q model %q", id, r, w.status, w.kind, w.model)
+		}
+	}
+	if got["claude_code_cli"].Path != exportPath {
+		t.Errorf("export target path = %q, want %q", got["claude_code_cli"].Path, exportPath)
+	}
+}
@@ -0,0 +1,186 @@
+// toml.go — Minimal TOML reader and writer for ~/.a21e/config.toml.
+//
+// Like yaml.go this covers only what the config file needs: [table] and
+// [dotted.table] headers, key = value pairs with bare or quoted keys, basic and
+// literal strings, integers, booleans and # comments. Arrays, inline tables,
+// multi-line strings and dates are rejected. Every value is returned as a string.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tomlTables maps a table name ("" for the root) to its key/value pairs.
+type tomlTables map[string]map[string]string
+
+func parseTOML(data []byte) (tomlTables, error) {
+	tables := tomlTables{"": {}}
+	current := ""
+	for i, raw := range strings.Split(string(data), "\n") {
+		num := i + 1
+		line := strings.TrimSpace(stripTOMLComment(raw))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unsupported table header %q", num, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			parts := strings.Split(name, ".")
+			for j, p := range parts {
+				p = strings.TrimSpace(p)
+				if !isBareTOMLKey(p) {
+					return nil, fmt.Errorf("line %d: invalid table name %q", num, name)
+				}
+				parts[j] = p
+			}
+			current = strings.Join(parts, ".")
+			if _, dup := tables[current]; dup && current != "" {
+				return nil, fmt.Errorf("line %d: table [%s] defined twice", num, current)
+			}
+			tables[current] = map[string]string{}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", num)
+		}
+		key, err := parseTOMLKey(strings.TrimSpace(line[:eq]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", num, err)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", num, key, err)
+		}
+		if _, dup := tables[current][key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", num, key)
+		}
+		tables[current][key] = value
+	}
+	return tables, nil
+}
+
+// stripTOMLComment removes a # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isBareTOMLKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTOMLKey(s string) (string, error) {
+	if isBareTOMLKey(s) {
+		return s, nil
+	}
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		return parseTOMLValue(s)
+	}
+	return "", fmt.Errorf("invalid key %q (dotted keys are not supported)", s)
+}
+
+func parseTOMLValue(s string) (string, error) {
+	switch {
+	case s == "":
+		return "", fmt.Errorf("missing value")
+	case s[0] == '"':
+		if strings.HasPrefix(s, `"""`) {
+			return "", fmt.Errorf("multi-line strings are not supported")
+		}
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if strings.HasPrefix(s, "'''") || len(s) < 2 || !strings.HasSuffix(s, "'") || strings.Contains(s[1:len(s)-1], "'") {
+			return "", fmt.Errorf("invalid literal string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case s == "true" || s == "false":
+		return s, nil
+	case s[0] == '[' || s[0] == '{':
+		return "", fmt.Errorf("arrays and inline tables are not supported")
+	}
+	if _, err := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 10, 64); err == nil {
+		return s, nil
+	}
+	return "", fmt.Errorf("unsupported value %s (quote strings)", s)
+}
+
+// renderTOML writes tables with every value as a basic string: the root table first,
+// then the others in name order. keyOrder lists known keys in the order to write
+// them; other keys follow alphabetically.
+func renderTOML(header string, tables tomlTables, keyOrder []string) []byte {
+	var b strings.Builder
+	b.WriteString(header)
+	writeTable := func(values map[string]string) {
+		for _, k := range orderedTOMLKeys(values, keyOrder) {
+			name := k
+			if !isBareTOMLKey(k) {
+				name = strconv.Quote(k)
+			}
+			fmt.Fprintf(&b, "%s = %s\n", name, strconv.Quote(values[k]))
+		}
+	}
+	writeTable(tables[""])
+	names := make([]string, 0, len(tables))
+	for name, values := range tables {
+		if name != "" && len(values) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n[%s]\n", name)
+		writeTable(tables[name])
+	}
+	return []byte(b.String())
+}
+
+func orderedTOMLKeys(values map[string]string, keyOrder []string) []string {
+	var keys, rest []string
+	known := map[string]bool{}
+	for _, k := range keyOrder {
+		known[k] = true
+		if _, ok := values[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	for k := range values {
+		if !known[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
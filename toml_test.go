@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		input   string
+		want    tomlTables
+		wantErr bool
+	}{
+		{
+			name:  "root and profile tables",
+			input: "# defaults\nmodel = \"gpt-4o\" # pinned\nscope='workspace'\n\n[profiles.eu]\napi_url = \"https://eu.a21e.com/#frag\"\n",
+			want: tomlTables{
+				"":            {"model": "gpt-4o", "scope": "workspace"},
+				"profiles.eu": {"api_url": "https://eu.a21e.com/#frag"},
+			},
+		},
+		{
+			name:  "escapes, quoted keys, ints and bools",
+			input: "\"odd key\" = \"a\\\"b\\tc\"\nretries = 1_000\nverbose = true\n",
+			want:  tomlTables{"": {"odd key": "a\"b\tc", "retries": "1_000", "verbose": "true"}},
+		},
+		{name: "duplicate key", input: "a = \"1\"\na = \"2\"\n", wantErr: true},
+		{name: "duplicate table", input: "[x]\n[x]\n", wantErr: true},
+		{name: "array", input: "a = [1, 2]\n", wantErr: true},
+		{name: "bare string", input: "a = hello\n", wantErr: true},
+		{name: "array of tables", input: "[[x]]\n", wantErr: true},
+		{name: "multi-line string", input: "a = \"\"\"x\n\"\"\"\n", wantErr: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseTOML([]byte(tc.input))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseTOML error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("parseTOML = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderTOMLRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tables := tomlTables{
+		"":             {"scope": "user", "api_url": "https://a21e.example", "zzz": "q\"uote"},
+		"profiles.dev": {"model": "o3-mini"},
+		"profiles.nil": {},
+	}
+	out := renderTOML("# header\n\n", tables, []string{"api_url", "model", "scope"})
+	want := "# header\n\napi_url = \"https://a21e.example\"\nscope = \"user\"\nzzz = \"q\\\"uote\"\n\n[profiles.dev]\nmodel = \"o3-mini\"\n"
+	if string(out) != want {
+		t.Fatalf("renderTOML =\n%s\nwant\n%s", out, want)
+	}
+	parsed, err := parseTOML(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed[""]["zzz"] != "q\"uote" || parsed["profiles.dev"]["model"] != "o3-mini" {
+		t.Fatalf("round trip = %#v", parsed)
+	}
+}
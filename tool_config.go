@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -17,112 +18,140 @@ type applySummary struct {
 	UpdatedPath string
 	BackupPath  string
 	Details     string
+	Unchanged   bool     // target already had the expected values; nothing was written
+	Markers     []string // managed block markers in UpdatedPath
+	Settings    []string // JSON setting names in UpdatedPath
+	Model       string   // model written as the tool's default
 }
 
+// openAIBaseURL is the OpenAI-compatible base URL for apiBaseURL (see discovery.go).
 func openAIBaseURL(apiBaseURL string) string {
-	trimmed := strings.TrimSpace(strings.TrimRight(apiBaseURL, "/"))
-	if trimmed == "" {
-		return "https://api.a21e.com/v1"
-	}
-	if strings.HasSuffix(trimmed, "/v1") {
-		return trimmed
-	}
-	return trimmed + "/v1"
+	return endpointsFor(apiBaseURL).OpenAI
 }
 
-func applyToolConfiguration(toolID, toolKey, apiBaseURL string) (*applySummary, error) {
-	switch toolID {
-	case "vscode":
-		path, backup, err := upsertEditorSettings("Code", toolKey, apiBaseURL)
-		if err != nil {
-			return nil, err
-		}
-		return &applySummary{
-			UpdatedPath: path,
-			BackupPath:  backup,
-			Details:     "Updated VS Code user settings for the a21e extension.",
-		}, nil
-	case "cursor":
-		path, backup, err := upsertEditorSettings("Cursor", toolKey, apiBaseURL)
-		if err != nil {
-			return nil, err
-		}
-		return &applySummary{
-			UpdatedPath: path,
-			BackupPath:  backup,
-			Details:     "Updated Cursor user settings for the a21e extension.",
-		}, nil
-	case "openai_cli_custom":
-		path, backup, err := upsertShellEnvBlock(toolID, toolKey, apiBaseURL)
-		if err != nil {
-			return nil, err
-		}
-		return &applySummary{
-			UpdatedPath: path,
-			BackupPath:  backup,
-			Details:     "Updated shell profile with OPENAI-compatible a21e environment variables.",
-		}, nil
-	case "codex_cli", "claude_code_cli", "jetbrains":
-		return nil, errAutoConfigUnsupported
-	default:
+func applyToolConfiguration(toolID, toolKey, apiBaseURL, model string) (*applySummary, error) {
+	t, ok := lookupTool(toolID)
+	if !ok {
 		return nil, errAutoConfigUnsupported
 	}
+	return t.Apply(toolKey, apiBaseURL, model)
 }
 
-func upsertEditorSettings(appName, toolKey, apiBaseURL string) (string, string, error) {
+func upsertEditorSettings(appName, toolKey, apiBaseURL, model string) (*applySummary, error) {
 	settingsPath, err := resolveEditorSettingsPath(appName)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	if err := os.MkdirAll(filepath.Dir(settingsPath), 0o755); err != nil {
-		return "", "", fmt.Errorf("could not prepare editor settings directory: %w", err)
+		return nil, fmt.Errorf("could not prepare editor settings directory: %w", err)
 	}
 
 	existing, err := os.ReadFile(settingsPath)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return "", "", fmt.Errorf("could not read editor settings file: %w", err)
+		return nil, fmt.Errorf("could not read editor settings file: %w", err)
 	}
 
-	updated, changed, err := mergeA21ESettings(existing, toolKey, apiBaseURL)
+	updated, changed, err := mergeA21ESettings(existing, toolKey, apiBaseURL, model)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
+	names := a21eSettingNames()
 	if !changed {
-		return settingsPath, "", nil
+		return &applySummary{UpdatedPath: settingsPath, Unchanged: true, Settings: names, Model: model}, nil
 	}
 
 	backup, err := writeFileWithBackup(settingsPath, existing, updated, 0o600)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+	return &applySummary{UpdatedPath: settingsPath, BackupPath: backup, Settings: names, Model: model}, nil
+}
+
+type editorSetting struct {
+	key   string
+	value string
+}
+
+// expectedA21ESettings lists the editor settings a21e manages, in write order.
+// Callers resolve ep (endpointsFor may fetch the discovery document) once.
+func expectedA21ESettings(toolKey string, ep endpoints, model string) []editorSetting {
+	return []editorSetting{
+		{key: "a21e.apiUrl", value: ep.API},
+		{key: "a21e.apiKey", value: toolKey},
+		{key: "a21e.defaultModel", value: model},
 	}
-	return settingsPath, backup, nil
 }
 
-func mergeA21ESettings(existing []byte, toolKey, apiBaseURL string) ([]byte, bool, error) {
+func a21eSettingNames() []string {
+	var names []string
+	for _, s := range expectedA21ESettings("", endpoints{}, "") {
+		names = append(names, s.key)
+	}
+	return names
+}
+
+func parseEditorSettings(existing []byte) (map[string]any, error) {
 	settings := map[string]any{}
 	if len(strings.TrimSpace(string(existing))) > 0 {
 		if err := json.Unmarshal(existing, &settings); err != nil {
-			return nil, false, fmt.Errorf(
+			return nil, fmt.Errorf(
 				"settings JSON is invalid. Back up and fix it, then rerun a21e init --apply: %w",
 				err,
 			)
 		}
 	}
+	return settings, nil
+}
+
+func mergeA21ESettings(existing []byte, toolKey, apiBaseURL, model string) ([]byte, bool, error) {
+	settings, err := parseEditorSettings(existing)
+	if err != nil {
+		return nil, false, err
+	}
 
 	changed := false
-	changed = setSetting(settings, "a21e.apiUrl", strings.TrimSuffix(openAIBaseURL(apiBaseURL), "/v1")) || changed
-	changed = setSetting(settings, "a21e.apiKey", toolKey) || changed
-	changed = setSetting(settings, "a21e.defaultModel", "a21e-auto") || changed
+	for _, s := range expectedA21ESettings(toolKey, endpointsFor(apiBaseURL), model) {
+		changed = setSetting(settings, s.key, s.value) || changed
+	}
 
-	out, err := json.MarshalIndent(settings, "", "  ")
+	out, err := marshalEditorSettings(settings)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, changed, nil
+}
+
+// removeA21ESettings drops the settings mergeA21ESettings manages, keeping everything else.
+func removeA21ESettings(existing []byte) ([]byte, bool, error) {
+	settings, err := parseEditorSettings(existing)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := false
+	for _, s := range expectedA21ESettings("", endpoints{}, "") {
+		if _, ok := settings[s.key]; ok {
+			delete(settings, s.key)
+			changed = true
+		}
+	}
+
+	out, err := marshalEditorSettings(settings)
 	if err != nil {
-		return nil, false, fmt.Errorf("could not serialize editor settings: %w", err)
+		return nil, false, err
 	}
-	out = append(out, '\n')
 	return out, changed, nil
 }
 
+func marshalEditorSettings(settings map[string]any) ([]byte, error) {
+	out, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize editor settings: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
 func setSetting(target map[string]any, key, value string) bool {
 	current, ok := target[key]
 	if ok {
@@ -150,42 +179,49 @@ func resolveEditorSettingsPath(appName string) (string, error) {
 	}
 }
 
-func upsertShellEnvBlock(toolID, toolKey, apiBaseURL string) (string, string, error) {
+func upsertShellEnvBlock(toolID, toolKey, apiBaseURL, model string) (*applySummary, error) {
 	rcPath, err := resolveShellRCPath()
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	existing, err := os.ReadFile(rcPath)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return "", "", fmt.Errorf("could not read shell profile: %w", err)
+		return nil, fmt.Errorf("could not read shell profile: %w", err)
 	}
 
-	blockStart := fmt.Sprintf("# >>> a21e %s >>>", toolID)
-	blockEnd := fmt.Sprintf("# <<< a21e %s <<<", toolID)
-	openAIURL := openAIBaseURL(apiBaseURL)
-	block := strings.Join([]string{
-		blockStart,
-		fmt.Sprintf("export OPENAI_API_BASE=%q", openAIURL),
-		fmt.Sprintf("export OPENAI_BASE_URL=%q", openAIURL),
-		fmt.Sprintf("export OPENAI_API_KEY=%q", toolKey),
-		"export A21E_MODEL=\"a21e-auto\"",
-		blockEnd,
-	}, "\n")
+	blockStart, blockEnd, block := shellEnvBlock(toolID, toolKey, apiBaseURL, model)
 
 	updated, changed, err := upsertManagedBlock(string(existing), blockStart, blockEnd, block)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
+	markers := []string{blockStart, blockEnd}
 	if !changed {
-		return rcPath, "", nil
+		return &applySummary{UpdatedPath: rcPath, Unchanged: true, Markers: markers, Model: model}, nil
 	}
 
 	backup, err := writeFileWithBackup(rcPath, existing, []byte(updated), 0o600)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return rcPath, backup, nil
+	return &applySummary{UpdatedPath: rcPath, BackupPath: backup, Markers: markers, Model: model}, nil
+}
+
+// shellEnvBlock returns the markers and content of the managed shell profile block for toolID.
+func shellEnvBlock(toolID, toolKey, apiBaseURL, model string) (string, string, string) {
+	blockStart := fmt.Sprintf("# >>> a21e %s >>>", toolID)
+	blockEnd := fmt.Sprintf("# <<< a21e %s <<<", toolID)
+	openAIURL := openAIBaseURL(apiBaseURL)
+	block := strings.Join([]string{
+		blockStart,
+		fmt.Sprintf("export OPENAI_API_BASE=%q", openAIURL),
+		fmt.Sprintf("export OPENAI_BASE_URL=%q", openAIURL),
+		fmt.Sprintf("export OPENAI_API_KEY=%q", toolKey),
+		fmt.Sprintf("export A21E_MODEL=%q", model),
+		blockEnd,
+	}, "\n")
+	return blockStart, blockEnd, block
 }
 
 func resolveShellRCPath() (string, error) {
@@ -194,6 +230,9 @@ func resolveShellRCPath() (string, error) {
 		return "", fmt.Errorf("could not resolve home directory: %w", err)
 	}
 
+	if rc := settingValue("shell_rc"); rc != "" {
+		return expandHome(rc), nil
+	}
 	switch filepath.Base(os.Getenv("SHELL")) {
 	case "zsh":
 		return filepath.Join(home, ".zshrc"), nil
@@ -230,11 +269,150 @@ func upsertManagedBlock(content, startMarker, endMarker, block string) (string,
 	return trimmed + "\n\n" + block + "\n", true, nil
 }
 
+// findManagedBlock returns the text between startMarker and endMarker, if both are present.
+func findManagedBlock(content, startMarker, endMarker string) (string, bool) {
+	start := strings.Index(content, startMarker)
+	end := strings.Index(content, endMarker)
+	if start < 0 || end < start {
+		return "", false
+	}
+	return content[start+len(startMarker) : end], true
+}
+
+// managedBlockValue reads NAME from a managed block written as NAME=value,
+// export NAME="value" or export NAME='value'.
+func managedBlockValue(block, name string) string {
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "export ")
+		value, ok := strings.CutPrefix(line, name+"=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+			return unquoted
+		}
+		if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+			return strings.ReplaceAll(value[1:len(value)-1], `'\''`, "'")
+		}
+		return value
+	}
+	return ""
+}
+
+// removeManagedBlock deletes the block between startMarker and endMarker (inclusive)
+// along with the blank line upsertManagedBlock put in front of it.
+func removeManagedBlock(content, startMarker, endMarker string) (string, bool, error) {
+	start := strings.Index(content, startMarker)
+	end := strings.Index(content, endMarker)
+	if start < 0 && end < 0 {
+		return content, false, nil
+	}
+	if start < 0 || end < 0 || end < start {
+		return "", false, errors.New("found unbalanced a21e markers in existing profile")
+	}
+	end += len(endMarker)
+	before := strings.TrimRight(content[:start], "\n")
+	after := strings.TrimLeft(content[end:], "\n")
+	switch {
+	case before == "":
+		return after, true, nil
+	case after == "":
+		return before + "\n", true, nil
+	default:
+		return before + "\n\n" + after, true, nil
+	}
+}
+
+// writeFileAtomic replaces path with data: it writes a temporary file in the same
+// directory, syncs it and renames it over path, so readers see either the old or the
+// new contents even if the write is interrupted. The new file gets mode perm and, when
+// like is non-nil, the owner and group of like (as far as we are allowed to set them).
+func writeFileAtomic(path string, data []byte, perm os.FileMode, like os.FileInfo) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file for %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not set permissions on %s: %w", path, err)
+	}
+	if like != nil {
+		if err := chownLike(tmp, like); err != nil {
+			tmp.Close()
+			return fmt.Errorf("could not preserve owner of %s: %w", path, err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("could not replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// maxSymlinkHops matches the kernel's limit for following a chain of symlinks.
+const maxSymlinkHops = 40
+
+// resolveSymlinks follows path to the file it ultimately names. Unlike
+// filepath.EvalSymlinks it also resolves a link whose target does not exist yet, so a
+// dotfile manager's dangling link is written through instead of replaced.
+func resolveSymlinks(path string) (string, error) {
+	for i := 0; i < maxSymlinkHops; i++ {
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return "", fmt.Errorf("too many levels of symbolic links")
+}
+
+// writeFileWithBackup backs up oldBytes next to path and atomically replaces path with
+// newBytes. Symlinks are written through to their target (so stow/chezmoi links keep
+// working), and an existing file keeps its mode and owner; perm only applies to new
+// files.
 func writeFileWithBackup(path string, oldBytes, newBytes []byte, perm os.FileMode) (string, error) {
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	target, err := resolveSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
 		return "", fmt.Errorf("could not create directory for %s: %w", path, err)
 	}
 
+	var like os.FileInfo
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+		like = info
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("could not read %s: %w", path, err)
+	}
+
 	backupPath := ""
 	if len(oldBytes) > 0 {
 		backupPath = fmt.Sprintf("%s.bak-%s", path, time.Now().UTC().Format("20060102T150405Z"))
@@ -243,8 +421,8 @@ func writeFileWithBackup(path string, oldBytes, newBytes []byte, perm os.FileMod
 		}
 	}
 
-	if err := os.WriteFile(path, newBytes, perm); err != nil {
-		return "", fmt.Errorf("could not write %s: %w", path, err)
+	if err := writeFileAtomic(target, newBytes, perm, like); err != nil {
+		return "", err
 	}
 	return backupPath, nil
 }
@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestOpenAIBaseURL(t *testing.T) {
 	t.Parallel()
@@ -74,7 +78,7 @@ func TestMergeA21ESettings(t *testing.T) {
 	t.Parallel()
 
 	base := []byte("{\n  \"editor.fontSize\": 14\n}\n")
-	updated, changed, err := mergeA21ESettings(base, "a21e_test_key", "https://api.a21e.com")
+	updated, changed, err := mergeA21ESettings(base, "a21e_test_key", "https://api.a21e.com", defaultModel)
 	if err != nil {
 		t.Fatalf("merge returned unexpected error: %v", err)
 	}
@@ -82,7 +86,7 @@ func TestMergeA21ESettings(t *testing.T) {
 		t.Fatalf("expected merge to report changed=true for first update")
 	}
 
-	updatedAgain, changedAgain, err := mergeA21ESettings(updated, "a21e_test_key", "https://api.a21e.com")
+	updatedAgain, changedAgain, err := mergeA21ESettings(updated, "a21e_test_key", "https://api.a21e.com", defaultModel)
 	if err != nil {
 		t.Fatalf("second merge returned unexpected error: %v", err)
 	}
@@ -93,3 +97,97 @@ func TestMergeA21ESettings(t *testing.T) {
 		t.Fatalf("expected second merge output to remain unchanged")
 	}
 }
+
+func TestWriteFileWithBackupKeepsMode(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".zshrc")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil { // undo umask
+		t.Fatal(err)
+	}
+	backup, err := writeFileWithBackup(path, []byte("old\n"), []byte("new\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, "new\n")
+	assertFile(t, backup, "old\n")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o644 {
+		t.Errorf("mode = %o, want 644 preserved", perm)
+	}
+
+	fresh := filepath.Join(filepath.Dir(path), "sub", "settings.json")
+	if _, err := writeFileWithBackup(fresh, nil, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("new file mode = %o, want 600", perm)
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*.tmp-*")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestWriteFileWithBackupWritesThroughSymlinks(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	dotfiles := filepath.Join(dir, "dotfiles")
+	if err := os.Mkdir(dotfiles, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dotfiles, "zshrc")
+	if err := os.WriteFile(target, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".zshrc")
+	if err := os.Symlink("dotfiles/zshrc", link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	// A dangling link (the target has not been created yet) is written through too.
+	dangling := filepath.Join(dir, ".bashrc")
+	if err := os.Symlink(filepath.Join(dotfiles, "bashrc"), dangling); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writeFileWithBackup(link, []byte("old\n"), []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeFileWithBackup(dangling, nil, []byte("fresh\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range []string{link, dangling} {
+		info, err := os.Lstat(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s was replaced by a regular file", l)
+		}
+	}
+	assertFile(t, target, "new\n")
+	assertFile(t, filepath.Join(dotfiles, "bashrc"), "fresh\n")
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
@@ -0,0 +1,449 @@
+// tools.go — Registry of supported tools.
+//
+// Every tool a21e can create keys for is a Tool in toolRegistry. Validation, the
+// tool list in usage text, environment detection, --apply and the README "Supported
+// tools" table are all derived from it, so adding a tool means adding one entry here.
+//
+// After changing the registry, regenerate the README table with:
+//
+//	go test -run TestReadmeToolTable -update
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Tool is a coding tool that can use an a21e key.
+type Tool interface {
+	// ID is the tool_id accepted by --tool. Keys are created with apiToolID(ID()).
+	ID() string
+	// Label is the human-readable tool name.
+	Label() string
+	// Detect reports whether the current process runs inside this tool, using getenv
+	// to read environment variables. It returns nil when the tool is not detected.
+	Detect(getenv func(string) string) *detection
+	// Apply writes the key and base URL into the tool's configuration.
+	Apply(toolKey, apiBaseURL, model string) (*applySummary, error)
+	// Unapply removes everything Apply wrote.
+	Unapply() (*applySummary, error)
+	// Verify compares the tool's configuration with what Apply would write.
+	Verify(toolKey, apiBaseURL, model string) (*verifyReport, error)
+	// CurrentKey returns the key the tool is configured with now, or "" if none.
+	CurrentKey() (string, error)
+	// Target returns the file Apply writes, or errAutoConfigUnsupported.
+	Target() (string, error)
+	// Doc describes the tool for usage text and the README.
+	Doc() toolDoc
+}
+
+type toolDoc struct {
+	Editors     string // README "Editor" column
+	AutoDetect  bool
+	AutoApply   string    // empty when --apply is unsupported
+	Agent       *cliAgent // set for CLI agents that detectCLIAgent recognises
+	Executables []string  // commands on PATH that show the tool is installed
+}
+
+// verifyReport is the result of Tool.Verify. Drift is empty when the target matches.
+type verifyReport struct {
+	Path  string
+	Drift []string
+}
+
+var toolRegistry = []Tool{
+	&editorTool{id: "cursor", label: "Cursor", appName: "Cursor", executables: []string{"cursor"}, termProgram: "cursor", termConfidence: confidenceHigh},
+	&editorTool{id: "vscode", label: "VS Code", appName: "Code", executables: []string{"code"}, termProgram: "vscode", termConfidence: confidenceLow},
+	&editorTool{id: "vscode_insiders", label: "VS Code Insiders", appName: "Code - Insiders", executables: []string{"code-insiders"}, apiID: "vscode"},
+	&editorTool{id: "windsurf", label: "Windsurf", appName: "Windsurf", executables: []string{"windsurf"}, apiID: "vscode"},
+	&manualTool{id: "jetbrains", label: "JetBrains", editors: "IntelliJ, PyCharm, etc.", detect: detectJetBrains,
+		executables: []string{"idea", "pycharm", "goland", "webstorm", "clion", "rider", "phpstorm", "rubymine"}},
+	&manualTool{id: "claude_code_cli", label: "Claude Code", executables: []string{"claude"}, agent: &cliAgent{
+		name: "Claude Code", envMarkers: []string{"CLAUDECODE", "CLAUDE_CODE_ENTRYPOINT"}, processNames: []string{"claude"},
+	}},
+	&manualTool{id: "codex_cli", label: "Codex CLI", executables: []string{"codex"}, agent: &cliAgent{
+		name: "Codex", envMarkers: []string{"CODEX_SANDBOX", "CODEX_SANDBOX_NETWORK_DISABLED"}, processNames: []string{"codex"},
+	}},
+	&shellEnvTool{id: "openai_cli_custom", label: "OpenAI-compatible CLI", editors: "OpenAI-compatible CLIs (e.g. Aider)", executables: []string{"aider"}, agent: &cliAgent{
+		name: "Aider", processNames: []string{"aider"},
+	}},
+}
+
+var validToolIDs = registeredToolIDs()
+
+func registeredToolIDs() []string {
+	ids := make([]string, 0, len(toolRegistry))
+	for _, t := range toolRegistry {
+		ids = append(ids, t.ID())
+	}
+	return ids
+}
+
+func lookupTool(id string) (Tool, bool) {
+	for _, t := range toolRegistry {
+		if t.ID() == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func isValidToolID(id string) bool {
+	_, ok := lookupTool(id)
+	return ok
+}
+
+// apiToolID returns the tool_id the API creates keys for. The API only knows
+// "vscode" for the VS Code family, so forks are sent as vscode and told apart
+// locally (settings path, detection, key label).
+func apiToolID(id string) string {
+	if t, ok := lookupTool(id); ok {
+		if e, ok := t.(*editorTool); ok && e.apiID != "" {
+			return e.apiID
+		}
+	}
+	return id
+}
+
+func suggestLabel(toolID string) string {
+	if t, ok := lookupTool(toolID); ok {
+		return t.Label() + " API key"
+	}
+	return "CLI API key"
+}
+
+// toolAutoDetects reports whether detectTool can select t without --tool.
+func toolAutoDetects(t Tool) bool {
+	doc := t.Doc()
+	return doc.AutoDetect || doc.Agent != nil
+}
+
+// renderToolTable renders the README "Supported tools" table.
+func renderToolTable() string {
+	var b strings.Builder
+	b.WriteString("| Tool ID | Editor | Auto-detect | Auto-apply |\n")
+	b.WriteString("|---------|--------|:-----------:|:----------:|\n")
+	for _, t := range toolRegistry {
+		doc := t.Doc()
+		detect := "No"
+		if toolAutoDetects(t) {
+			detect = "Yes"
+		}
+		apply := "No — configure manually"
+		if doc.AutoApply != "" {
+			apply = "Yes — " + doc.AutoApply
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", t.ID(), doc.Editors, detect, apply)
+	}
+	return b.String()
+}
+
+// editorTool is a VS Code–family editor configured through its user settings.json.
+// Forks that share TERM_PROGRAM=vscode are told apart by detectVSCodeFamily.
+type editorTool struct {
+	id             string
+	label          string
+	appName        string // settings directory name, e.g. "Code" or "Cursor"
+	termProgram    string // TERM_PROGRAM value set by the integrated terminal, if unique enough
+	termConfidence string
+	apiID          string // tool_id sent to the API when it differs from id
+	executables    []string
+}
+
+func (t *editorTool) ID() string    { return t.id }
+func (t *editorTool) Label() string { return t.label }
+
+func (t *editorTool) Detect(getenv func(string) string) *detection {
+	if t.termProgram == "" || getenv("TERM_PROGRAM") != t.termProgram {
+		return nil
+	}
+	return &detection{ToolID: t.id, Confidence: t.termConfidence, Signal: "TERM_PROGRAM=" + t.termProgram}
+}
+
+func (t *editorTool) Apply(toolKey, apiBaseURL, model string) (*applySummary, error) {
+	summary, err := upsertEditorSettings(t.appName, toolKey, apiBaseURL, model)
+	if err != nil {
+		return nil, err
+	}
+	summary.Details = fmt.Sprintf("Updated %s user settings for the a21e extension.", t.label)
+	return summary, nil
+}
+
+func (t *editorTool) Unapply() (*applySummary, error) {
+	summary, err := removeEditorSettings(t.appName)
+	if err != nil {
+		return nil, err
+	}
+	summary.Details = fmt.Sprintf("Removed a21e settings from %s user settings.", t.label)
+	return summary, nil
+}
+
+func (t *editorTool) Verify(toolKey, apiBaseURL, model string) (*verifyReport, error) {
+	return verifyEditorSettings(t.appName, toolKey, apiBaseURL, model)
+}
+
+func (t *editorTool) CurrentKey() (string, error) {
+	settingsPath, err := resolveEditorSettingsPath(t.appName)
+	if err != nil {
+		return "", err
+	}
+	existing, err := os.ReadFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read editor settings file: %w", err)
+	}
+	settings, err := parseEditorSettings(existing)
+	if err != nil {
+		return "", err
+	}
+	key, _ := settings["a21e.apiKey"].(string)
+	return key, nil
+}
+
+func (t *editorTool) Target() (string, error) {
+	return resolveEditorSettingsPath(t.appName)
+}
+
+func (t *editorTool) Doc() toolDoc {
+	return toolDoc{Editors: t.label, AutoDetect: true, AutoApply: fmt.Sprintf("patches %s user settings", t.label), Executables: t.executables}
+}
+
+// shellEnvTool is configured through OPENAI_* variables in a managed shell profile block.
+type shellEnvTool struct {
+	id          string
+	label       string
+	editors     string
+	agent       *cliAgent
+	executables []string
+}
+
+func (t *shellEnvTool) ID() string                            { return t.id }
+func (t *shellEnvTool) Label() string                         { return t.label }
+func (t *shellEnvTool) Detect(func(string) string) *detection { return nil }
+
+func (t *shellEnvTool) Apply(toolKey, apiBaseURL, model string) (*applySummary, error) {
+	summary, err := upsertShellEnvBlock(t.id, toolKey, apiBaseURL, model)
+	if err != nil {
+		return nil, err
+	}
+	summary.Details = "Updated shell profile with OPENAI-compatible a21e environment variables."
+	return summary, nil
+}
+
+func (t *shellEnvTool) Unapply() (*applySummary, error) {
+	summary, err := removeShellEnvBlock(t.id)
+	if err != nil {
+		return nil, err
+	}
+	summary.Details = "Removed a21e environment variables from shell profile."
+	return summary, nil
+}
+
+func (t *shellEnvTool) Verify(toolKey, apiBaseURL, model string) (*verifyReport, error) {
+	return verifyShellEnvBlock(t.id, toolKey, apiBaseURL, model)
+}
+
+func (t *shellEnvTool) CurrentKey() (string, error) {
+	rcPath, err := resolveShellRCPath()
+	if err != nil {
+		return "", err
+	}
+	existing, err := os.ReadFile(rcPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read shell profile: %w", err)
+	}
+	blockStart, blockEnd, _ := shellEnvBlock(t.id, "", "", "")
+	block, ok := findManagedBlock(string(existing), blockStart, blockEnd)
+	if !ok {
+		return "", nil
+	}
+	return managedBlockValue(block, "OPENAI_API_KEY"), nil
+}
+
+func (t *shellEnvTool) Target() (string, error) {
+	return resolveShellRCPath()
+}
+
+func (t *shellEnvTool) Doc() toolDoc {
+	return toolDoc{Editors: t.editors, AutoApply: "sets shell env vars", Agent: t.agent, Executables: t.executables}
+}
+
+// manualTool has no automatic configuration; users paste the values from init.
+type manualTool struct {
+	id          string
+	label       string
+	editors     string // defaults to label
+	detect      func(getenv func(string) string) *detection
+	agent       *cliAgent
+	executables []string
+}
+
+func (t *manualTool) ID() string    { return t.id }
+func (t *manualTool) Label() string { return t.label }
+
+func (t *manualTool) Detect(getenv func(string) string) *detection {
+	if t.detect == nil {
+		return nil
+	}
+	return t.detect(getenv)
+}
+
+func (t *manualTool) Apply(string, string, string) (*applySummary, error) {
+	return nil, errAutoConfigUnsupported
+}
+
+func (t *manualTool) Unapply() (*applySummary, error) {
+	return nil, errAutoConfigUnsupported
+}
+
+func (t *manualTool) Verify(string, string, string) (*verifyReport, error) {
+	return nil, errAutoConfigUnsupported
+}
+
+func (t *manualTool) CurrentKey() (string, error) {
+	return "", errAutoConfigUnsupported
+}
+
+func (t *manualTool) Target() (string, error) {
+	return "", errAutoConfigUnsupported
+}
+
+func (t *manualTool) Doc() toolDoc {
+	editors := t.editors
+	if editors == "" {
+		editors = t.label
+	}
+	return toolDoc{Editors: editors, AutoDetect: t.detect != nil, Agent: t.agent, Executables: t.executables}
+}
+
+func detectJetBrains(getenv func(string) string) *detection {
+	v := getenv("TERMINAL_EMULATOR")
+	if !strings.Contains(v, "JetBrains") {
+		return nil
+	}
+	return &detection{ToolID: "jetbrains", Confidence: confidenceMedium, Signal: "TERMINAL_EMULATOR=" + v}
+}
+
+// removeEditorSettings deletes the a21e.* keys that upsertEditorSettings writes.
+func removeEditorSettings(appName string) (*applySummary, error) {
+	settingsPath, err := resolveEditorSettingsPath(appName)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := os.ReadFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &applySummary{UpdatedPath: settingsPath, Unchanged: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read editor settings file: %w", err)
+	}
+	updated, changed, err := removeA21ESettings(existing)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return &applySummary{UpdatedPath: settingsPath, Unchanged: true}, nil
+	}
+	backup, err := writeFileWithBackup(settingsPath, existing, updated, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &applySummary{UpdatedPath: settingsPath, BackupPath: backup}, nil
+}
+
+// verifyEditorSettings reports every a21e.* setting that differs from what
+// mergeA21ESettings would write.
+func verifyEditorSettings(appName, toolKey, apiBaseURL, model string) (*verifyReport, error) {
+	settingsPath, err := resolveEditorSettingsPath(appName)
+	if err != nil {
+		return nil, err
+	}
+	report := &verifyReport{Path: settingsPath}
+	existing, err := os.ReadFile(settingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		report.Drift = append(report.Drift, "settings file is missing")
+		return report, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read editor settings file: %w", err)
+	}
+	current, err := parseEditorSettings(existing)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range expectedA21ESettings(toolKey, endpointsFor(apiBaseURL), model) {
+		got, ok := current[s.key].(string)
+		switch {
+		case !ok:
+			report.Drift = append(report.Drift, fmt.Sprintf("%s is missing", s.key))
+		case got != s.value && s.key == "a21e.apiKey":
+			report.Drift = append(report.Drift, fmt.Sprintf("%s is %s, want %s", s.key, maskKey(got), maskKey(s.value)))
+		case got != s.value:
+			report.Drift = append(report.Drift, fmt.Sprintf("%s is %q, want %q", s.key, got, s.value))
+		}
+	}
+	return report, nil
+}
+
+func removeShellEnvBlock(toolID string) (*applySummary, error) {
+	rcPath, err := resolveShellRCPath()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := os.ReadFile(rcPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &applySummary{UpdatedPath: rcPath, Unchanged: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read shell profile: %w", err)
+	}
+	blockStart, blockEnd, _ := shellEnvBlock(toolID, "", "", "")
+	updated, changed, err := removeManagedBlock(string(existing), blockStart, blockEnd)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return &applySummary{UpdatedPath: rcPath, Unchanged: true}, nil
+	}
+	backup, err := writeFileWithBackup(rcPath, existing, []byte(updated), 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &applySummary{UpdatedPath: rcPath, BackupPath: backup}, nil
+}
+
+// verifyShellEnvBlock reports whether the managed block in the shell profile still
+// matches what upsertShellEnvBlock would write.
+func verifyShellEnvBlock(toolID, toolKey, apiBaseURL, model string) (*verifyReport, error) {
+	rcPath, err := resolveShellRCPath()
+	if err != nil {
+		return nil, err
+	}
+	report := &verifyReport{Path: rcPath}
+	existing, err := os.ReadFile(rcPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read shell profile: %w", err)
+	}
+	blockStart, blockEnd, block := shellEnvBlock(toolID, toolKey, apiBaseURL, model)
+	if !strings.Contains(string(existing), blockStart) {
+		report.Drift = append(report.Drift, "managed block is missing")
+		return report, nil
+	}
+	_, changed, err := upsertManagedBlock(string(existing), blockStart, blockEnd, block)
+	if err != nil {
+		report.Drift = append(report.Drift, err.Error())
+		return report, nil
+	}
+	if changed {
+		report.Drift = append(report.Drift, "managed block was edited")
+	}
+	return report, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var updateReadme = flag.Bool("update", false, "rewrite generated README sections")
+
+func TestToolRegistry(t *testing.T) {
+	testCases := []struct {
+		id         string
+		keyLabel   string
+		apiID      string
+		detectEnv  map[string]string
+		otherEnv   map[string]string
+		canApply   bool
+		detectable bool
+	}{
+		{id: "cursor", keyLabel: "Cursor API key", apiID: "cursor", detectEnv: map[string]string{"TERM_PROGRAM": "cursor"}, otherEnv: map[string]string{"TERM_PROGRAM": "vscode"}, canApply: true, detectable: true},
+		{id: "vscode", keyLabel: "VS Code API key", apiID: "vscode", detectEnv: map[string]string{"TERM_PROGRAM": "vscode"}, otherEnv: map[string]string{"TERM_PROGRAM": "iTerm.app"}, canApply: true, detectable: true},
+		{id: "vscode_insiders", keyLabel: "VS Code Insiders API key", apiID: "vscode", otherEnv: map[string]string{"TERM_PROGRAM": "vscode"}, canApply: true, detectable: true},
+		{id: "windsurf", keyLabel: "Windsurf API key", apiID: "vscode", otherEnv: map[string]string{"TERM_PROGRAM": "vscode"}, canApply: true, detectable: true},
+		{id: "jetbrains", keyLabel: "JetBrains API key", apiID: "jetbrains", detectEnv: map[string]string{"TERMINAL_EMULATOR": "JetBrains-JediTerm"}, otherEnv: map[string]string{"TERM_PROGRAM": "vscode"}, detectable: true},
+		{id: "claude_code_cli", keyLabel: "Claude Code API key", apiID: "claude_code_cli", detectable: true},
+		{id: "codex_cli", keyLabel: "Codex CLI API key", apiID: "codex_cli", detectable: true},
+		{id: "openai_cli_custom", keyLabel: "OpenAI-compatible CLI API key", apiID: "openai_cli_custom", canApply: true, detectable: true},
+	}
+
+	if len(testCases) != len(toolRegistry) {
+		t.Fatalf("registry has %d tools, test covers %d", len(toolRegistry), len(testCases))
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			tool, ok := lookupTool(tc.id)
+			if !ok {
+				t.Fatalf("tool %q is not registered", tc.id)
+			}
+			if got := suggestLabel(tc.id); got != tc.keyLabel {
+				t.Fatalf("suggestLabel = %q, want %q", got, tc.keyLabel)
+			}
+			if got := apiToolID(tc.id); got != tc.apiID {
+				t.Fatalf("apiToolID = %q, want %q", got, tc.apiID)
+			}
+			if tc.detectEnv != nil {
+				if d := tool.Detect(mapEnv(tc.detectEnv)); d == nil || d.ToolID != tc.id {
+					t.Fatalf("expected Detect to match %v", tc.detectEnv)
+				}
+			}
+			if tool.Detect(mapEnv(tc.otherEnv)) != nil {
+				t.Fatalf("expected Detect not to match %v", tc.otherEnv)
+			}
+			if toolAutoDetects(tool) != tc.detectable {
+				t.Fatalf("toolAutoDetects = %v, want %v", toolAutoDetects(tool), tc.detectable)
+			}
+
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("SHELL", "/bin/zsh")
+
+			summary, err := tool.Apply("a21e_test_key_0000000001", "https://api.a21e.com", defaultModel)
+			if !tc.canApply {
+				if !errors.Is(err, errAutoConfigUnsupported) {
+					t.Fatalf("Apply error = %v, want errAutoConfigUnsupported", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply returned unexpected error: %v", err)
+			}
+			if summary.Unchanged || summary.UpdatedPath == "" {
+				t.Fatalf("expected first Apply to write a file, got %+v", summary)
+			}
+
+			report, err := tool.Verify("a21e_test_key_0000000001", "https://api.a21e.com", defaultModel)
+			if err != nil || len(report.Drift) != 0 {
+				t.Fatalf("Verify after Apply = %+v, %v; want no drift", report, err)
+			}
+			report, err = tool.Verify("a21e_other_key_000000002", "https://api.a21e.com", defaultModel)
+			if err != nil || len(report.Drift) == 0 {
+				t.Fatalf("Verify with another key = %+v, %v; want drift", report, err)
+			}
+
+			if _, err := tool.Unapply(); err != nil {
+				t.Fatalf("Unapply returned unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(summary.UpdatedPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(content), "a21e_test_key_0000000001") {
+				t.Fatalf("Unapply left the key behind:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestRemoveManagedBlock(t *testing.T) {
+	t.Parallel()
+
+	const start = "# >>> a21e openai_cli_custom >>>"
+	const end = "# <<< a21e openai_cli_custom <<<"
+	original := "export PATH=\"$HOME/.local/bin:$PATH\"\n"
+
+	inserted, _, err := upsertManagedBlock(original, start, end, start+"\nline\n"+end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, changed, err := removeManagedBlock(inserted, start, end)
+	if err != nil {
+		t.Fatalf("remove returned unexpected error: %v", err)
+	}
+	if !changed || removed != original {
+		t.Fatalf("remove = %q (changed=%v), want %q", removed, changed, original)
+	}
+
+	again, changed, err := removeManagedBlock(removed, start, end)
+	if err != nil || changed || again != removed {
+		t.Fatalf("expected removing an absent block to be a no-op")
+	}
+}
+
+func TestReadmeToolTable(t *testing.T) {
+	const startMarker = "<!-- tools:start"
+	const endMarker = "<!-- tools:end -->"
+
+	raw, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(raw)
+	start := strings.Index(content, startMarker)
+	end := strings.Index(content, endMarker)
+	if start < 0 || end < 0 {
+		t.Fatalf("README.md is missing the tools table markers")
+	}
+	start += strings.Index(content[start:], "\n") + 1
+
+	want := renderToolTable()
+	if content[start:end] == want {
+		return
+	}
+	if !*updateReadme {
+		t.Fatalf("README tool table is out of date; run go test -run TestReadmeToolTable -update")
+	}
+	if err := os.WriteFile("README.md", []byte(content[:start]+want+content[end:]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mapEnv(env map[string]string) func(string) string {
+	return func(k string) string { return env[k] }
+}
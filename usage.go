@@ -0,0 +1,239 @@
+// usage.go — `a21e usage`: per-key, per-tool and per-day consumption of a workspace.
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// usageKey is one key's totals over the period, with its daily cost for the sparkline.
+type usageKey struct {
+	KeyID     string    `json:"key_id"`
+	KeyPrefix string    `json:"key_prefix"`
+	ToolID    string    `json:"tool_id"`
+	Requests  int64     `json:"requests"`
+	Tokens    int64     `json:"tokens"`
+	CostUSD   float64   `json:"cost_usd"`
+	DailyCost []float64 `json:"daily_cost_usd"`
+}
+
+type usageReport struct {
+	Workspace string     `json:"workspace"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Days      []string   `json:"days"`
+	Keys      []usageKey `json:"keys"`
+	Total     usageKey   `json:"total"`
+	Rows      []usageRow `json:"rows"`
+}
+
+// usageFlags are the options of a21e usage.
+type usageFlags struct {
+	workspaceID *string
+	days        *int
+	format      *string
+	file        *string
+}
+
+func newUsageFlagSet() (*flag.FlagSet, usageFlags) {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	return fs, usageFlags{
+		workspaceID: fs.String("workspace", "", "Workspace ID (omit to use default)"),
+		days:        fs.Int("days", 30, "Number of days to show, ending today (UTC)"),
+		format:      fs.String("format", "table", "Output format: table, csv or json"),
+		file:        fs.String("file", "", "Write csv or json to this file instead of stdout"),
+	}
+}
+
+func runUsage(args []string) {
+	fs, f := newUsageFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if jsonOutput() {
+		*f.format = "json"
+	}
+	switch {
+	case *f.format != "table" && *f.format != "csv" && *f.format != "json":
+		exitWithError("usage", withExitCode(exitValidation, fmt.Errorf("invalid --format %q (want table, csv or json)", *f.format)))
+	case *f.days < 1 || *f.days > 366:
+		exitWithError("usage", withExitCode(exitValidation, errors.New("--days must be between 1 and 366")))
+	case *f.file != "" && *f.format == "table":
+		exitWithError("usage", withExitCode(exitValidation, errors.New("--file needs --format csv or json")))
+	}
+
+	apiKey := requireAPIKey("usage")
+	baseURL := getAPIBaseURL()
+	wid := *f.workspaceID
+	if wid == "" {
+		ws, err := getDefaultWorkspace(apiKey, baseURL)
+		if err != nil {
+			exitWithError("usage", err)
+		}
+		wid = ws.ID
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -(*f.days - 1))
+	resp, err := getWorkspaceUsage(apiKey, baseURL, wid, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		exitWithError("usage", err)
+	}
+	report := summarizeUsage(wid, from, to, resp.Rows)
+
+	if *f.format == "table" {
+		renderUsageTable(os.Stdout, report)
+		return
+	}
+	if *f.file == "" {
+		if err := writeUsageReport(os.Stdout, *f.format, report); err != nil {
+			exitWithError("usage", err)
+		}
+		return
+	}
+	// Close explicitly: exitWithError skips deferred calls, and a failed Close can
+	// mean the data never reached the disk.
+	file, err := os.Create(*f.file)
+	if err != nil {
+		exitWithError("usage", err)
+	}
+	err = writeUsageReport(file, *f.format, report)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("could not write %s: %w", *f.file, closeErr)
+	}
+	if err != nil {
+		exitWithError("usage", err)
+	}
+	fmt.Fprintf(humanOut(), "Wrote %d rows to %s\n", len(report.Rows), *f.file)
+}
+
+// writeUsageReport writes report to out as csv or json.
+func writeUsageReport(out io.Writer, format string, report usageReport) error {
+	var err error
+	if format == "csv" {
+		err = writeUsageCSV(out, report.Rows)
+	} else {
+		err = encodeJSON(out, report)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", format, err)
+	}
+	return nil
+}
+
+// summarizeUsage totals rows per key and per day for every day from..to, so days
+// without usage show as zero in the sparklines.
+func summarizeUsage(workspace string, from, to time.Time, rows []usageRow) usageReport {
+	report := usageReport{
+		Workspace: workspace,
+		From:      from.Format(time.DateOnly),
+		To:        to.Format(time.DateOnly),
+		Rows:      rows,
+		Keys:      []usageKey{},
+	}
+	dayIndex := map[string]int{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dayIndex[d.Format(time.DateOnly)] = len(report.Days)
+		report.Days = append(report.Days, d.Format(time.DateOnly))
+	}
+	report.Total = usageKey{KeyID: "total", DailyCost: make([]float64, len(report.Days))}
+
+	byKey := map[string]*usageKey{}
+	for _, r := range rows {
+		k := byKey[r.KeyID]
+		if k == nil {
+			k = &usageKey{KeyID: r.KeyID, KeyPrefix: r.KeyPrefix, ToolID: r.ToolID, DailyCost: make([]float64, len(report.Days))}
+			byKey[r.KeyID] = k
+		}
+		for _, acc := range []*usageKey{k, &report.Total} {
+			acc.Requests += r.Requests
+			acc.Tokens += r.InputTokens + r.OutputTokens
+			acc.CostUSD += r.CostUSD
+			if i, ok := dayIndex[r.Date]; ok {
+				acc.DailyCost[i] += r.CostUSD
+			}
+		}
+	}
+	for _, k := range byKey {
+		report.Keys = append(report.Keys, *k)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		if report.Keys[i].CostUSD != report.Keys[j].CostUSD {
+			return report.Keys[i].CostUSD > report.Keys[j].CostUSD
+		}
+		return report.Keys[i].KeyID < report.Keys[j].KeyID
+	})
+	return report
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline scales values to block characters; zero stays at the lowest tick.
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkTicks)-1)))
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
+
+func renderUsageTable(w io.Writer, report usageReport) {
+	fmt.Fprintf(w, "Usage for workspace %s, %s to %s\n\n", report.Workspace, report.From, report.To)
+	if len(report.Keys) == 0 {
+		fmt.Fprintln(w, "No usage in this period.")
+		return
+	}
+	fmt.Fprintf(w, "%-16s %-18s %9s %12s %10s  %s\n", "KEY", "TOOL", "REQUESTS", "TOKENS", "COST", "DAILY COST")
+	line := func(k usageKey, label, tool string) {
+		fmt.Fprintf(w, "%-16s %-18s %9d %12d %10s  %s\n", label, tool, k.Requests, k.Tokens, formatUSD(k.CostUSD), sparkline(k.DailyCost))
+	}
+	for _, k := range report.Keys {
+		label := k.KeyPrefix + "…"
+		if k.KeyPrefix == "" {
+			label = k.KeyID
+		}
+		line(k, label, k.ToolID)
+	}
+	line(report.Total, "TOTAL", "")
+}
+
+func formatUSD(v float64) string {
+	return "$" + strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func writeUsageCSV(w io.Writer, rows []usageRow) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "key_id", "key_prefix", "tool_id", "requests", "input_tokens", "output_tokens", "cost_usd"})
+	for _, r := range rows {
+		_ = cw.Write([]string{
+			r.Date, r.KeyID, r.KeyPrefix, r.ToolID,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.InputTokens, 10),
+			strconv.FormatInt(r.OutputTokens, 10),
+			strconv.FormatFloat(r.CostUSD, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
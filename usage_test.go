@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usageFixtureServer serves testdata/usage.json, a recorded /usage response.
+func usageFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	fixture, err := os.ReadFile("testdata/usage.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/workspaces/ws_1/usage" || q.Get("from") != "2026-10-12" || q.Get("to") != "2026-10-18" || q.Get("group_by") != "key,tool_id,day" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(fixture)
+	}))
+}
+
+func TestUsageReport(t *testing.T) {
+	t.Parallel()
+
+	srv := usageFixtureServer(t)
+	defer srv.Close()
+	resp, err := getWorkspaceUsage("k", srv.URL, "ws_1", "2026-10-12", "2026-10-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, _ := time.Parse(time.DateOnly, "2026-10-12")
+	to, _ := time.Parse(time.DateOnly, "2026-10-18")
+	report := summarizeUsage("ws_1", from, to, resp.Rows)
+
+	if len(report.Days) != 7 || len(report.Keys) != 2 {
+		t.Fatalf("days %d, keys %d; want 7 and 2", len(report.Days), len(report.Keys))
+	}
+	top := report.Keys[0]
+	if top.KeyID != "key_2" || top.Requests != 240 || top.Tokens != 620000 {
+		t.Fatalf("top key = %+v, want key_2 with 240 requests and 620000 tokens", top)
+	}
+	if report.Total.Requests != 387 {
+		t.Errorf("total requests = %d, want 387", report.Total.Requests)
+	}
+
+	var table bytes.Buffer
+	renderUsageTable(&table, report)
+	for _, want := range []string{
+		"a21e_live_cd…    claude_code_cli          240       620000      $4.51  ▁█▁▁▁▁▂",
+		"a21e_live_ab…    cursor                   147       222850      $1.55  ▄▁█▁▁▁▂",
+		"TOTAL                                     387       842850      $6.06",
+	} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table is missing %q:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeUsageCSV(&out, report.Rows); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 || records[0][0] != "date" || records[4][3] != "claude_code_cli" || records[4][7] != "3.9" {
+		t.Fatalf("csv = %v", records)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		values []float64
+		want   string
+	}{
+		{values: []float64{0, 0, 0}, want: "▁▁▁"},
+		{values: []float64{0, 1, 2, 3, 4, 5, 6, 7}, want: "▁▂▃▄▅▆▇█"},
+		{values: []float64{5}, want: "█"},
+	}
+	for _, tc := range testCases {
+		if got := sparkline(tc.values); got != tc.want {
+			t.Errorf("sparkline(%v) = %q, want %q", tc.values, got, tc.want)
+		}
+	}
+}
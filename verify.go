@@ -0,0 +1,238 @@
+// verify.go — `a21e verify`: check that applied tool configuration has not drifted.
+//
+// Every auto-applied target (each tool whose target has a21e configuration, or that
+// state.json says was applied) is compared with what Apply would write for the key it
+// holds, and that key is checked against the server's list of active keys. With --fix,
+// drifted targets are re-applied; a missing or revoked key is replaced with a new one.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// verifyTarget is the outcome for one applied tool.
+type verifyTarget struct {
+	Tool   string   `json:"tool"`
+	Path   string   `json:"path"`
+	KeyID  string   `json:"key_id,omitempty"`
+	Key    string   `json:"key_masked,omitempty"`
+	Status string   `json:"status"` // ok, drifted, fixed or failed
+	Drift  []string `json:"drift"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type verifyOutput struct {
+	Targets []verifyTarget `json:"targets"`
+	Drifted int            `json:"drifted"`
+	Fixed   int            `json:"fixed"`
+	Failed  int            `json:"failed"`
+}
+
+// verifier holds what checking every target needs.
+type verifier struct {
+	apiKey     string
+	baseURL    string
+	fix        bool
+	activeKeys []apiKeyListItem
+	state      *cliState
+	workspace  string // default workspace, fetched when --fix first needs a new key
+}
+
+func newVerifyFlagSet() (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	return fs, fs.Bool("fix", false, "Re-apply drifted targets (replacing revoked keys)")
+}
+
+func runVerify(args []string) {
+	fs, fix := newVerifyFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+
+	baseURL := getAPIBaseURL()
+	apiKey := requireAPIKey("verify")
+	st, err := loadState()
+	if err != nil {
+		exitWithError("verify", err)
+	}
+	active, err := listAPIKeysForUser(apiKey, baseURL)
+	if err != nil {
+		exitWithError("verify", err)
+	}
+
+	v := &verifier{apiKey: apiKey, baseURL: baseURL, fix: *fix, activeKeys: active, state: st}
+	out := verifyOutput{Targets: []verifyTarget{}}
+	exitCode := exitOK
+	for _, tool := range appliedTools(st) {
+		res := v.verifyTool(tool)
+		switch res.Status {
+		case "drifted":
+			out.Drifted++
+			if exitCode == exitOK {
+				exitCode = exitDrift
+			}
+		case "fixed":
+			out.Fixed++
+		case "failed":
+			out.Failed++
+			if exitCode == exitOK || exitCode == exitDrift {
+				exitCode = exitApply
+			}
+		}
+		out.Targets = append(out.Targets, res)
+	}
+
+	if jsonOutput() {
+		writeJSON(out)
+	} else {
+		printVerifyOutput(out)
+	}
+	os.Exit(exitCode)
+}
+
+// appliedTools returns the auto-applicable tools a21e has configured on this machine:
+// those state.json says were applied, and those whose target holds an a21e key.
+func appliedTools(st *cliState) []Tool {
+	var tools []Tool
+	for _, tool := range toolRegistry {
+		target, err := tool.Target()
+		if err != nil {
+			continue
+		}
+		if st.applyFor(tool.ID(), target) != nil {
+			tools = append(tools, tool)
+			continue
+		}
+		if key, err := tool.CurrentKey(); err != nil || key != "" {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+func (v *verifier) verifyTool(tool Tool) verifyTarget {
+	res := verifyTarget{Tool: tool.ID(), Drift: []string{}}
+	target, err := tool.Target()
+	if err != nil {
+		return failVerify(res, err)
+	}
+	res.Path = target
+	rec := v.state.applyFor(tool.ID(), target)
+	model := appliedModel(rec)
+
+	key, err := tool.CurrentKey()
+	if err != nil {
+		return failVerify(res, err)
+	}
+	keyUsable := false
+	if key == "" {
+		res.Drift = append(res.Drift, "a21e configuration was removed")
+	} else {
+		res.Key = maskKey(key)
+		report, err := tool.Verify(key, v.baseURL, model)
+		if err != nil {
+			return failVerify(res, err)
+		}
+		res.Drift = append(res.Drift, report.Drift...)
+		if rec != nil {
+			if applied := v.state.keyByID(rec.KeyID); applied != nil && applied.Prefix != keyPrefixFromRaw(key) {
+				res.Drift = append(res.Drift, fmt.Sprintf("key was replaced: a21e applied %s…, found %s", applied.Prefix, res.Key))
+			}
+		}
+		if item := findActiveKey(v.activeKeys, key); item != nil {
+			res.KeyID = item.ID
+			keyUsable = true
+		} else {
+			res.Drift = append(res.Drift, fmt.Sprintf("key %s is not active on the server (revoked, or from another account)", res.Key))
+		}
+	}
+
+	if len(res.Drift) == 0 {
+		res.Status = "ok"
+		return res
+	}
+	if !v.fix {
+		res.Status = "drifted"
+		return res
+	}
+
+	if !keyUsable {
+		resp, err := v.replacementKey(tool.ID(), rec)
+		if err != nil {
+			return failVerify(res, err)
+		}
+		key, res.KeyID, res.Key = resp.Key, resp.ID, maskKey(resp.Key)
+	}
+	summary, err := tool.Apply(key, v.baseURL, model)
+	if err != nil {
+		return failVerify(res, err)
+	}
+	warnState(recordApply(res.KeyID, tool.ID(), summary))
+	res.Status = "fixed"
+	return res
+}
+
+// replacementKey creates a key for toolID in the workspace and scope of the key a21e
+// last applied there, or a user-scoped key in the default workspace.
+func (v *verifier) replacementKey(toolID string, rec *stateApply) (*createCliKeyResp, error) {
+	workspace, scope := "", "user"
+	if rec != nil {
+		if k := v.state.keyByID(rec.KeyID); k != nil {
+			workspace, scope = k.Workspace, k.Scope
+		}
+	}
+	if workspace == "" {
+		if v.workspace == "" {
+			ws, err := getDefaultWorkspace(v.apiKey, v.baseURL)
+			if err != nil {
+				return nil, err
+			}
+			v.workspace = ws.ID
+		}
+		workspace = v.workspace
+	}
+	resp, err := createCLIKey(v.apiKey, v.baseURL, workspace, toolID, suggestLabel(toolID), scope)
+	if err != nil {
+		return nil, err
+	}
+	warnState(recordKey(resp, toolID, workspace, scope))
+	return resp, nil
+}
+
+func failVerify(res verifyTarget, err error) verifyTarget {
+	res.Status = "failed"
+	res.Error = err.Error()
+	return res
+}
+
+func printVerifyOutput(out verifyOutput) {
+	if len(out.Targets) == 0 {
+		fmt.Fprintln(os.Stderr, "No applied configuration found. Run a21e init --apply first.")
+		return
+	}
+	for _, t := range out.Targets {
+		switch t.Status {
+		case "failed":
+			fmt.Fprintf(os.Stderr, "  %-18s FAILED: %s\n", t.Tool, t.Error)
+			continue
+		case "ok":
+			fmt.Fprintf(os.Stderr, "  %-18s ok (%s)\n", t.Tool, t.Path)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %-18s %s (%s)\n", t.Tool, t.Status, t.Path)
+		for _, d := range t.Drift {
+			fmt.Fprintf(os.Stderr, "      - %s\n", d)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d targets drifted, %d fixed, %d failed.\n", out.Drifted+out.Fixed, len(out.Targets), out.Fixed, out.Failed)
+	if out.Drifted > 0 {
+		fmt.Fprintln(os.Stderr, "Run a21e verify --fix to re-apply.")
+	}
+}
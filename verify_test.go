@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDetectsAndFixesDrift(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SHELL", "/bin/zsh")
+
+	fake := &fakeKeyServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	settings := initSettings{apiKey: "admin", baseURL: srv.URL, workspace: "ws_1", scope: "user", model: defaultModel, apply: true}
+	for _, id := range []string{"cursor", "openai_cli_custom"} {
+		if _, _, err := setupTool(settings, id, false, io.Discard); err != nil {
+			t.Fatalf("setupTool(%s): %v", id, err)
+		}
+	}
+
+	run := func(fix bool) map[string]verifyTarget {
+		t.Helper()
+		active, err := listAPIKeysForUser("admin", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, err := loadState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := &verifier{apiKey: "admin", baseURL: srv.URL, fix: fix, activeKeys: active, state: st}
+		results := map[string]verifyTarget{}
+		for _, tool := range appliedTools(st) {
+			results[tool.ID()] = v.verifyTool(tool)
+		}
+		return results
+	}
+	expectStatus := func(results map[string]verifyTarget, want map[string]string) {
+		t.Helper()
+		if len(results) != len(want) {
+			t.Fatalf("verified %d targets, want %d: %+v", len(results), len(want), results)
+		}
+		for id, status := range want {
+			if got := results[id]; got.Status != status {
+				t.Errorf("%s: status %q, want %q (drift %v, error %q)", id, got.Status, status, got.Drift, got.Error)
+			}
+		}
+	}
+
+	expectStatus(run(false), map[string]string{"cursor": "ok", "openai_cli_custom": "ok"})
+
+	// A teammate changes the model in Cursor, and the shell key is revoked.
+	cursor, _ := lookupTool("cursor")
+	settingsPath, _ := cursor.Target()
+	raw, _ := os.ReadFile(settingsPath)
+	if err := os.WriteFile(settingsPath, []byte(strings.Replace(string(raw), "a21e-auto", "gpt-4o", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	inactive := false
+	fake.keys[1].IsActive = &inactive
+	fake.mu.Unlock()
+
+	results := run(false)
+	expectStatus(results, map[string]string{"cursor": "drifted", "openai_cli_custom": "drifted"})
+	if d := strings.Join(results["cursor"].Drift, "; "); !strings.Contains(d, "a21e.defaultModel") {
+		t.Errorf("cursor drift = %q, want a21e.defaultModel", d)
+	}
+	if d := strings.Join(results["openai_cli_custom"].Drift, "; "); !strings.Contains(d, "not active") {
+		t.Errorf("shell drift = %q, want inactive key", d)
+	}
+
+	expectStatus(run(true), map[string]string{"cursor": "fixed", "openai_cli_custom": "fixed"})
+	if fake.created() != 3 {
+		t.Errorf("created %d keys, want 3 (one replacement for the revoked key)", fake.created())
+	}
+	expectStatus(run(false), map[string]string{"cursor": "ok", "openai_cli_custom": "ok"})
+}
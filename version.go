@@ -0,0 +1,235 @@
+// version.go — `a21e version`: build, API and update information for bug reports.
+//
+// Build details come from the version/buildDate ldflags and runtime/debug build info
+// (VCS revision, commit time, dirty tree, Go version). The API version is read from
+// <api_url>/v1/version. The newest release on the update channel is looked up at
+// most once a day (cached in ~/.a21e/cache) unless update_check is false; --offline
+// skips both network calls.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// buildDate is set by release builds with -ldflags "-X main.buildDate=<RFC 3339>".
+var buildDate = ""
+
+const (
+	versionNetworkTimeout = 3 * time.Second
+	updateCheckInterval   = 24 * time.Hour
+	updateCheckCacheName  = "update-check"
+)
+
+type versionInfo struct {
+	Version    string        `json:"version"`
+	Revision   string        `json:"revision,omitempty"`
+	BuildDate  string        `json:"build_date,omitempty"`
+	Dirty      bool          `json:"dirty"`
+	GoVersion  string        `json:"go_version"`
+	Platform   string        `json:"platform"`
+	APIURL     string        `json:"api_url"`
+	APIVersion string        `json:"api_version,omitempty"`
+	APIError   string        `json:"api_error,omitempty"`
+	Update     *updateStatus `json:"update,omitempty"`
+}
+
+// updateStatus is the result of the (cached) release check.
+type updateStatus struct {
+	Channel    string    `json:"channel"`
+	ReleaseURL string    `json:"release_url"`
+	Latest     string    `json:"latest_version,omitempty"`
+	Available  bool      `json:"update_available"`
+	NotesURL   string    `json:"notes_url,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newVersionFlagSet() (*flag.FlagSet, *bool, *bool) {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON (same as --output json)")
+	offline := fs.Bool("offline", false, "Skip the API version and update checks")
+	return fs, asJSON, offline
+}
+
+func runVersion(args []string) {
+	fs, asJSON, offline := newVersionFlagSet()
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitValidation)
+	}
+	if *asJSON {
+		outputMode = outputJSON
+	}
+
+	info := buildVersionInfo()
+	if !*offline {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info.APIVersion, info.APIError = apiVersion(info.APIURL)
+		}()
+		if settingValue("update_check") == "true" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				info.Update = checkForUpdate(settingValue("release_url"), settingValue("update_channel"), info.Version)
+			}()
+		}
+		wg.Wait()
+	}
+
+	if jsonOutput() {
+		writeJSON(info)
+		return
+	}
+	printVersionInfo(os.Stdout, info)
+}
+
+// buildVersionInfo collects everything that does not need the network.
+func buildVersionInfo() *versionInfo {
+	info := &versionInfo{
+		Version:   version,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		APIURL:    getAPIBaseURL(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version // go install github.com/a21e/cli@vX.Y.Z
+	}
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Dirty, _ = strconv.ParseBool(s.Value)
+		}
+	}
+	return info
+}
+
+// apiVersion returns the version the API reports, or why it could not be read.
+func apiVersion(apiBaseURL string) (version, errMsg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionNetworkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointsFor(apiBaseURL).API+"/v1/version", nil)
+	if err != nil {
+		return "", err.Error()
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return "", err.Error()
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err.Error()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIStatusError(resp.StatusCode, raw).Error()
+	}
+	var body struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.Version == "" {
+		return "", "the API did not report a version"
+	}
+	return body.Version, ""
+}
+
+// checkForUpdate compares current with the channel's latest release, reusing a
+// check made in the last updateCheckInterval for the same channel and release URL.
+func checkForUpdate(releaseURL, channel, current string) *updateStatus {
+	var cached updateStatus
+	if readCache(updateCheckCacheName, updateCheckInterval, &cached) &&
+		cached.Channel == channel && cached.ReleaseURL == releaseURL && cached.Error == "" {
+		cached.Available = compareVersions(cached.Latest, current) > 0
+		return &cached
+	}
+
+	st := &updateStatus{Channel: channel, ReleaseURL: releaseURL, CheckedAt: time.Now().UTC()}
+	type fetched struct {
+		rel *releaseInfo
+		err error
+	}
+	done := make(chan fetched, 1)
+	go func() {
+		rel, err := fetchLatestRelease(releaseURL, channel)
+		done <- fetched{rel, err}
+	}()
+	select {
+	case f := <-done:
+		if f.err != nil {
+			st.Error = f.err.Error()
+			return st
+		}
+		st.Latest = f.rel.Version
+		st.NotesURL = f.rel.NotesURL
+	case <-time.After(versionNetworkTimeout):
+		st.Error = "timed out checking for updates"
+		return st
+	}
+	st.Available = compareVersions(st.Latest, current) > 0
+	_ = writeCache(updateCheckCacheName, st)
+	return st
+}
+
+func printVersionInfo(w io.Writer, info *versionInfo) {
+	fmt.Fprintln(w, "a21e", info.Version)
+	if info.Revision != "" {
+		rev := info.Revision
+		if info.Dirty {
+			rev += " (modified)"
+		}
+		fmt.Fprintf(w, "  revision:  %s\n", rev)
+	}
+	if info.BuildDate != "" {
+		fmt.Fprintf(w, "  built:     %s\n", info.BuildDate)
+	}
+	fmt.Fprintf(w, "  go:        %s %s\n", info.GoVersion, info.Platform)
+	switch {
+	case info.APIVersion != "":
+		fmt.Fprintf(w, "  api:       %s (version %s)\n", info.APIURL, info.APIVersion)
+	case info.APIError != "":
+		fmt.Fprintf(w, "  api:       %s (version unavailable: %s)\n", info.APIURL, info.APIError)
+	default:
+		fmt.Fprintf(w, "  api:       %s\n", info.APIURL)
+	}
+	if u := info.Update; u != nil {
+		switch {
+		case u.Error != "":
+			fmt.Fprintf(w, "  update:    could not check the %s channel: %s\n", u.Channel, u.Error)
+		case u.Available:
+			fmt.Fprintf(w, "  update:    %s is available on the %s channel; run a21e self-update\n", u.Latest, u.Channel)
+		default:
+			fmt.Fprintf(w, "  update:    up to date with the %s channel\n", u.Channel)
+		}
+	}
+}
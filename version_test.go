@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckForUpdateIsCached(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/stable/latest.json":
+			w.Write([]byte(`{"version":"1.4.0","notes_url":"https://example.test/notes"}`))
+		case "/beta/latest.json":
+			w.Write([]byte(`{"version":"1.5.0-beta.1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	st := checkForUpdate(srv.URL, "stable", "1.3.0")
+	if st.Error != "" || st.Latest != "1.4.0" || !st.Available || st.NotesURL == "" {
+		t.Fatalf("first check = %+v", st)
+	}
+	// A later version of the binary reuses the cached result without asking again.
+	st = checkForUpdate(srv.URL, "stable", "1.4.0")
+	if st.Latest != "1.4.0" || st.Available {
+		t.Fatalf("cached check = %+v", st)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("release server hit %d times, want 1", n)
+	}
+
+	st = checkForUpdate(srv.URL, "beta", "1.4.0")
+	if st.Latest != "1.5.0-beta.1" || !st.Available {
+		t.Fatalf("beta check = %+v", st)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("changing channel did not re-check (hits = %d)", n)
+	}
+
+	srv.Close()
+	st = checkForUpdate(srv.URL+"/gone", "stable", "1.4.0")
+	if st.Error == "" {
+		t.Fatalf("unreachable release server reported %+v", st)
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":"2026-09-01"}`))
+	}))
+	defer srv.Close()
+
+	if v, errMsg := apiVersion(srv.URL); v != "2026-09-01" || errMsg != "" {
+		t.Fatalf("apiVersion = %q, %q", v, errMsg)
+	}
+	if v, errMsg := apiVersion(srv.URL + "/missing"); v != "" || !strings.Contains(errMsg, "404") {
+		t.Fatalf("apiVersion on 404 = %q, %q", v, errMsg)
+	}
+}
+
+func TestPrintVersionInfo(t *testing.T) {
+	t.Parallel()
+	var b strings.Builder
+	printVersionInfo(&b, &versionInfo{
+		Version: "1.3.0", Revision: "abc123", Dirty: true, BuildDate: "2026-10-01T12:00:00Z",
+		GoVersion: "go1.22.5", Platform: "linux/amd64", APIURL: "https://api.a21e.com", APIVersion: "2026-09-01",
+		Update: &updateStatus{Channel: "stable", Latest: "1.4.0", Available: true},
+	})
+	for _, want := range []string{"a21e 1.3.0\n", "abc123 (modified)", "go1.22.5 linux/amd64", "(version 2026-09-01)", "1.4.0 is available on the stable channel"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// yaml.go — Minimal YAML reader for a21e manifest files.
+//
+// a21e has no third-party dependencies, so this supports only the subset a manifest
+// needs: block mappings and sequences, "- key: value" items, flow sequences of scalars
+// ([a, b]), single/double-quoted strings, booleans, integers, null and # comments.
+// Anchors, multi-line strings and flow mappings are rejected.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type yamlLine struct {
+	num    int // 1-based line number for errors
+	indent int
+	text   string
+}
+
+// decodeYAML parses data and stores the result in v using encoding/json rules
+// (json struct tags, DisallowUnknownFields).
+func decodeYAML(data []byte, v any) error {
+	value, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func parseYAML(data []byte) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(raw, "---") || strings.HasPrefix(raw, "...") {
+			continue
+		}
+		if leading := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]; strings.Contains(leading, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := stripYAMLComment(raw)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		lines = append(lines, yamlLine{num: i + 1, indent: indent, text: strings.TrimSpace(text)})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	value, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected content", p.lines[p.pos].num)
+	}
+	return value, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if !isYAMLSeqItem(line.text) {
+			break // "key:\n- a\nnext: …" — the sequence ends at the parent's next key
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			} else {
+				items = append(items, nil)
+			}
+			continue
+		}
+		if _, _, isMap := splitYAMLKey(rest); isMap || isYAMLSeqItem(rest) {
+			// "- key: value" starts a nested block whose indent is the item's content column.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: line.indent + len(line.text) - len(rest), text: rest}
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+		v, err := parseYAMLScalar(rest, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		p.pos++
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+		if rest != "" {
+			v, err := parseYAMLScalar(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		// Nested block: deeper indent, or a sequence at the same indent ("key:\n- a").
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLSeqItem(next.text)) {
+				v, err := p.parseBlock(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+				continue
+			}
+		}
+		m[key] = nil
+	}
+	return m, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: rest". Keys may be quoted.
+func splitYAMLKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, `'`) {
+		end := strings.Index(text[1:], text[:1])
+		if end < 0 {
+			return "", "", false
+		}
+		key := text[1 : end+1]
+		after := text[end+2:]
+		if !strings.HasPrefix(after, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(after[1:]), true
+	}
+	idx := strings.Index(text, ": ")
+	if idx < 0 {
+		if strings.HasSuffix(text, ":") {
+			idx = len(text) - 1
+		} else {
+			return "", "", false
+		}
+	}
+	key := strings.TrimSpace(text[:idx])
+	if key == "" || strings.ContainsAny(key, "[]{}\"'") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[idx+1:]), true
+}
+
+func parseYAMLScalar(s string, lineNum int) (any, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", lineNum)
+		}
+		items := []any{}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		if inner == "" {
+			return items, nil
+		}
+		for _, part := range strings.Split(inner, ",") {
+			v, err := parseYAMLScalar(strings.TrimSpace(part), lineNum)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(s, "{"):
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", lineNum)
+	case strings.HasPrefix(s, "&"), strings.HasPrefix(s, "*"), strings.HasPrefix(s, "|"), strings.HasPrefix(s, ">"):
+		return nil, fmt.Errorf("line %d: anchors and block scalars are not supported", lineNum)
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", lineNum, s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, `'`):
+		if len(s) < 2 || !strings.HasSuffix(s, `'`) {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", lineNum, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	case "null", "~":
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	return s, nil
+}
+
+// stripYAMLComment removes a trailing "# comment" that is not inside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" :[,-", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
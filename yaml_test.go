@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		input   string
+		want    any
+		wantErr bool
+	}{
+		{
+			name:  "scalars and comments",
+			input: "# team setup\nversion: 1\nworkspace: \"ws #1\" # quoted hash\nenabled: true\nempty:\n",
+			want:  map[string]any{"version": int64(1), "workspace": "ws #1", "enabled": true, "empty": nil},
+		},
+		{
+			name:  "sequence of scalars and mappings",
+			input: "tools:\n  - cursor\n  - id: vscode\n    scope: workspace\n    export:\n      format: dotenv\n",
+			want: map[string]any{"tools": []any{
+				"cursor",
+				map[string]any{"id": "vscode", "scope": "workspace", "export": map[string]any{"format": "dotenv"}},
+			}},
+		},
+		{
+			name:  "sequence at parent indent and flow list",
+			input: "tools:\n- cursor\n- codex_cli\nlabels: [a, 'b c', \"d\"]\n",
+			want:  map[string]any{"tools": []any{"cursor", "codex_cli"}, "labels": []any{"a", "b c", "d"}},
+		},
+		{name: "duplicate keys", input: "a: 1\na: 2\n", wantErr: true},
+		{name: "bad indentation", input: "a: 1\n  b: 2\n", wantErr: true},
+		{name: "flow mapping", input: "a: {b: 1}\n", wantErr: true},
+		{name: "tab indentation", input: "a:\n\tb: 1\n", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseYAML([]byte(tc.input))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseYAML returned unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("parseYAML = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}